@@ -3,7 +3,12 @@ package vshard_router //nolint:revive
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/tarantool/go-tarantool/v2"
@@ -22,8 +27,14 @@ const (
 	ReadMode  VshardMode = "read"
 	WriteMode VshardMode = "write"
 
+	// vshardStorageClientCall is a storage function that calls user defined function with bucket checks.
+	vshardStorageClientCall = "vshard.storage.call"
+
 	// callTimeoutDefault is a default timeout when no timeout is provided
 	callTimeoutDefault = 500 * time.Millisecond
+	// connectionRetryPause is a pause before the next attempt of a read request
+	// if the previous one has failed due to connection error.
+	connectionRetryPause = 10 * time.Millisecond
 )
 
 func (c VshardMode) String() string {
@@ -117,31 +128,56 @@ func (r *vshardStorageCallResponseProto) DecodeMsgpack(d *msgpack.Decoder) error
 	}
 
 	// isVShardRespOk is true
-	buf := bytes.NewBuffer(nil)
+	// The case when outputLen > 3 is not possible currently, since it is limited by 3
+	// due to lua vshard storage implementation. See:
+	// https://github.com/tarantool/vshard/blob/76b3ad19b539150bf597a5ffec91b97758b69a00/vshard/storage/init.lua#L3168
+	// However, it may change over time, so we should be ready to this.
+	buf, err := readCallRespValues(respArrayLen-1, d.Buffered())
+	if err != nil {
+		return err
+	}
+
+	r.CallResp.buf = buf
+
+	return nil
+}
+
+// readCallRespValues reads the rest of user defined function response into a buffer
+// prefixed with msgpack array header of length outputLen, so it can be decoded as an array.
+func readCallRespValues(outputLen int, rest io.Reader) (*bytes.Buffer, error) {
+	var header []byte
 
-	switch outputLen := respArrayLen - 1; {
+	switch {
 	case outputLen < 16:
-		// This way of encoding len for msgpack array works only for len < 16.
-		// The case when len >= 16 is not possible currently, since it is limited by 3
-		// due to lua vshard storage implementation. See:
-		// https://github.com/tarantool/vshard/blob/76b3ad19b539150bf597a5ffec91b97758b69a00/vshard/storage/init.lua#L3168
-		// However, it may change over time, so we should be ready to this.
-		err = buf.WriteByte(msgpcode.FixedArrayLow | byte(outputLen))
-		if err != nil {
-			return fmt.Errorf("can't buf.WriteByte to encode outputLen: %w", err)
-		}
+		header = []byte{msgpcode.FixedArrayLow | byte(outputLen)}
+	case outputLen <= math.MaxUint16:
+		header = []byte{msgpcode.Array16, 0, 0}
+		binary.BigEndian.PutUint16(header[1:], uint16(outputLen))
 	default:
-		return fmt.Errorf("unexpected outputLen: %d", outputLen)
+		header = []byte{msgpcode.Array32, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[1:], uint32(outputLen)) //nolint:gosec
 	}
 
-	_, err = buf.ReadFrom(d.Buffered())
-	if err != nil {
-		return fmt.Errorf("can't buf.ReadFrom: %w", err)
+	// go-tarantool decodes responses from an in-memory buffer with Len method (like bytes.Reader),
+	// so we know the size of the rest and can read it with a single allocation.
+	if sized, ok := rest.(interface{ Len() int }); ok {
+		data := make([]byte, len(header)+sized.Len())
+		copy(data, header)
+
+		if _, err := io.ReadFull(rest, data[len(header):]); err != nil {
+			return nil, fmt.Errorf("can't read response values: %w", err)
+		}
+
+		return bytes.NewBuffer(data), nil
 	}
 
-	r.CallResp.buf = buf
+	buf := bytes.NewBuffer(header)
 
-	return nil
+	if _, err := buf.ReadFrom(rest); err != nil {
+		return nil, fmt.Errorf("can't buf.ReadFrom: %w", err)
+	}
+
+	return buf, nil
 }
 
 type assertError struct {
@@ -188,7 +224,16 @@ type CallOpts struct {
 	// RouteRetryPause is a pause before the next attempt of r.Route request
 	// if the previous one fails. The default is 0, which corresponds
 	// to the lua router behavior: there is no pause in this case.
+	// It is used only if there is no RetryPolicy.
 	RouteRetryPause time.Duration
+	// RetryPolicy decides whether to retry a failed attempt, overrides Config.RetryPolicy.
+	// By default, the lua router behavior is reproduced.
+	RetryPolicy RetryPolicy
+	// Hedge enables hedged requests for CallModeRO and CallModeBRO, it is ignored for other modes.
+	Hedge *HedgeOpts
+	// Session provides read-your-writes consistency: read calls see the writes made by CallModeRW calls
+	// of the same session, see Session.
+	Session *Session
 }
 
 // CallMode is a type to represent call mode for Router.Call method.
@@ -201,7 +246,8 @@ const (
 	CallModeRW
 	// CallModeRE acts like CallModeRO
 	// with preference for a replica rather than a master.
-	// This mode is not supported yet.
+	// The master is used only when there are no available replicas.
+	// Connection errors are retried on other replicas within the call timeout.
 	CallModeRE
 	// CallModeBRO acts like CallModeRO with balancing.
 	CallModeBRO
@@ -210,6 +256,23 @@ const (
 	CallModeBRE
 )
 
+func (c CallMode) String() string {
+	switch c {
+	case CallModeRO:
+		return "RO"
+	case CallModeRW:
+		return "RW"
+	case CallModeRE:
+		return "RE"
+	case CallModeBRO:
+		return "BRO"
+	case CallModeBRE:
+		return "BRE"
+	default:
+		return fmt.Sprintf("CallMode(%d)", int(c))
+	}
+}
+
 // VshardRouterCallResp represents a response from Router.Call[XXX] methods.
 type VshardRouterCallResp struct {
 	buf *bytes.Buffer
@@ -231,31 +294,45 @@ func (r VshardRouterCallResp) GetTyped(result interface{}) error {
 // Call calls the function identified by 'fnc' on the shard storing the bucket identified by 'bucket_id'.
 func (r *Router) Call(ctx context.Context, bucketID uint64, mode CallMode,
 	fnc string, args interface{}, opts CallOpts) (VshardRouterCallResp, error) {
-	const vshardStorageClientCall = "vshard.storage.call"
+	return r.call(ctx, bucketID, mode, fnc, args, opts, nil)
+}
 
+// call implements Router.Call and Router.CallStream, onPush is nil for Router.Call.
+func (r *Router) call(ctx context.Context, bucketID uint64, mode CallMode,
+	fnc string, args interface{}, opts CallOpts, onPush func(push CallPush) error) (VshardRouterCallResp, error) {
+	if len(r.cfg.Interceptors) == 0 {
+		return r.callImpl(ctx, bucketID, mode, fnc, args, opts, onPush, nil)
+	}
+
+	info := &CallInfo{
+		BucketID: bucketID,
+		Mode:     mode,
+		Fnc:      fnc,
+		Args:     args,
+	}
+
+	reply, err := r.intercept(ctx, info, func(ctx context.Context, info *CallInfo) (interface{}, error) {
+		return r.callImpl(ctx, info.BucketID, info.Mode, info.Fnc, info.Args, opts, onPush, info)
+	})
+
+	return interceptedReply[VshardRouterCallResp](reply, err)
+}
+
+// callImpl performs the call, info is nil if there are no interceptors.
+func (r *Router) callImpl(ctx context.Context, bucketID uint64, mode CallMode, fnc string, args interface{},
+	opts CallOpts, onPush func(push CallPush) error, info *CallInfo) (VshardRouterCallResp, error) {
 	if bucketID < 1 || r.cfg.TotalBucketCount < bucketID {
 		return VshardRouterCallResp{}, fmt.Errorf("bucket id is out of range: %d (total %d)", bucketID, r.cfg.TotalBucketCount)
 	}
 
-	var poolMode pool.Mode
-	var vshardMode VshardMode
+	if err := r.beginRequest(); err != nil {
+		return VshardRouterCallResp{}, err
+	}
+	defer r.endRequest()
 
-	switch mode {
-	case CallModeRO:
-		poolMode, vshardMode = pool.RO, ReadMode
-	case CallModeRW:
-		poolMode, vshardMode = pool.RW, WriteMode
-	case CallModeRE:
-		// poolMode, vshardMode = pool.PreferRO, ReadMode
-		// since go-tarantool always use balance=true politic,
-		// we can't support this case until: https://github.com/tarantool/go-tarantool/issues/400
-		return VshardRouterCallResp{}, fmt.Errorf("mode VshardCallModeRE is not supported yet")
-	case CallModeBRO:
-		poolMode, vshardMode = pool.ANY, ReadMode
-	case CallModeBRE:
-		poolMode, vshardMode = pool.PreferRO, ReadMode
-	default:
-		return VshardRouterCallResp{}, fmt.Errorf("unknown VshardCallMode(%d)", mode)
+	poolMode, vshardMode, err := callModeToPoolMode(mode)
+	if err != nil {
+		return VshardRouterCallResp{}, err
 	}
 
 	timeout := callTimeoutDefault
@@ -266,20 +343,27 @@ func (r *Router) Call(ctx context.Context, bucketID uint64, mode CallMode,
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	tntReq := tarantool.NewCallRequest(vshardStorageClientCall).
-		Context(ctx).
-		Args([]interface{}{
-			bucketID,
-			vshardMode,
-			fnc,
-			args,
-		})
+	tntReq := newVshardStorageCallRequest(ctx, bucketID, vshardMode, fnc, args)
+
+	retryPolicy := r.retryPolicy(opts)
 
 	requestStartTime := time.Now()
 
-	var err error
+	// nextRs is a replicaset for the next attempt chosen by retry policy, nil means routing by bucket id.
+	var nextRs *Replicaset
 
-	for {
+	// pushed is true if any push message has been delivered to onPush, the call is not retried in this case.
+	var pushed bool
+
+	retry := func(reason string, attempt RetryAttempt) (*Replicaset, bool) {
+		if pushed {
+			return nil, false
+		}
+
+		return r.waitRetry(ctx, retryPolicy, reason, attempt, timeout)
+	}
+
+	for attempt := 1; ; attempt++ {
 		if spent := time.Since(requestStartTime); spent > timeout {
 			r.metrics().RequestDuration(spent, fnc, false, false)
 
@@ -291,114 +375,230 @@ func (r *Router) Call(ctx context.Context, bucketID uint64, mode CallMode,
 			return VshardRouterCallResp{}, err
 		}
 
-		var rs *Replicaset
+		retryAttempt := RetryAttempt{
+			Attempt:  attempt,
+			BucketID: bucketID,
+			Mode:     mode,
+		}
 
-		rs, err = r.Route(ctx, bucketID)
-		if err != nil {
-			r.metrics().RetryOnCall("bucket_resolve_error")
+		rs := nextRs
+		nextRs = nil
+
+		var routeErr error
+		if rs == nil {
+			rs, routeErr = r.Route(ctx, bucketID)
+		}
 
+		if routeErr != nil {
 			// this error will be returned to a caller in case of timeout
-			err = fmt.Errorf("cant resolve bucket %d: %w", bucketID, err)
+			err = fmt.Errorf("cant resolve bucket %d: %w", bucketID, routeErr)
+			info.addAttempt("", err)
 
-			// The lua vshard router just yields here and retires, no pause is applied.
-			// But without a pause we may have few problems, see: https://github.com/tarantool/go-vshard-router/issues/66.
-			if opts.RouteRetryPause > 0 {
-				time.Sleep(opts.RouteRetryPause)
+			var vshardError *StorageCallVShardError
+			if errors.As(err, &vshardError) {
+				retryAttempt.ErrName = vshardError.Name
+			}
+
+			retryAttempt.Elapsed, retryAttempt.Err = time.Since(requestStartTime), err
+
+			var ok bool
+			if nextRs, ok = retry("bucket_resolve_error", retryAttempt); !ok {
+				return VshardRouterCallResp{}, err
 			}
 
 			continue
 		}
 
+		retryAttempt.Replicaset = rs.info.Name
+
+		// err is not shadowed: the error of the last attempt is returned to a caller in case of timeout
+		var releaseLimits func()
+
+		releaseLimits, err = rs.acquireLimits(ctx, mode)
+		if err != nil {
+			info.addAttempt(rs.info.Name, err)
+
+			return VshardRouterCallResp{}, err
+		}
+
+		probe, allowed, lastErr := rs.breaker.allow()
+		if !allowed {
+			rs.abortLimits(mode)
+
+			err = newVShardErrorReplicasetInBackoff(rs.info, lastErr)
+			info.addAttempt(rs.info.Name, err)
+
+			return VshardRouterCallResp{}, err
+		}
+
 		r.log().Infof(ctx, "Try call %s on replicaset %s for bucket %d", fnc, rs.info.Name, bucketID)
 
 		storageCallResponse := vshardStorageCallResponseProto{}
 
-		err = rs.conn.Do(tntReq, poolMode).GetTyped(&storageCallResponse)
+		// instance is an instance chosen by the router, it is empty if the pool chooses an instance
+		attemptPoolMode, instance := poolMode, ""
+
+		_, hasToken := opts.Session.token(rs.info.Name)
+		hedged := !hasToken && opts.Hedge != nil && onPush == nil && (mode == CallModeRO || mode == CallModeBRO)
+
+		if !hedged {
+			// hedgedCall chooses the instances and handles their failures itself, within HedgeOpts.MaxRequests,
+			// so they are neither resent nor observed by the balancer here
+			attemptPoolMode, instance, err = r.attemptTarget(ctx, rs, mode, poolMode, opts.Session)
+		}
+
+		send := func(instance string, mode pool.Mode) error {
+			if onPush != nil {
+				return readPushes(rs.doInstance(tntReq, instance, mode), onPush, &pushed, &storageCallResponse)
+			}
+
+			return rs.doInstance(tntReq, instance, mode).GetTyped(&storageCallResponse)
+		}
+
+		attemptStart := time.Now()
+
+		switch {
+		case err != nil:
+			// there are no healthy replicas, err is pool.ErrNoRoInstance
+		case hedged:
+			err = r.hedgedCall(ctx, rs, poolMode, *opts.Hedge,
+				[]interface{}{bucketID, vshardMode, fnc, args}, &storageCallResponse)
+		default:
+			err = send(instance, attemptPoolMode)
+		}
+
+		if err != nil && instance != "" && !pushed && isConnectionError(err) {
+			// The instance has been chosen by the router, so the request bypasses the pool failover:
+			// the instance may have disconnected right after it has been chosen. The request is read-only,
+			// so it's safe to send it once more and let the pool choose another instance.
+			r.log().Debugf(ctx, "Instance %s of replicaset %s has failed, let the pool choose another one: %v",
+				instance, rs.info.Name, err)
+			rs.balancer.observe(instance, time.Since(attemptStart), true)
+
+			instance, attemptStart = "", time.Now()
+			err = send("", attemptPoolMode)
+		}
+
+		if err != nil && mode == CallModeRE && !pushed && errors.Is(err, pool.ErrNoRoInstance) {
+			// There are no available replicas, so fall back to the master as lua vshard router does.
+			r.log().Debugf(ctx, "No replicas available on replicaset %s for bucket %d, call master", rs.info.Name, bucketID)
+
+			err = send("", pool.RW)
+		}
+
+		releaseLimits()
+
+		failed := err != nil && (isTransportError(err) || errors.Is(ctx.Err(), context.DeadlineExceeded))
+		if failed {
+			rs.breaker.done(probe, err)
+		} else {
+			rs.breaker.done(probe, nil)
+		}
+
+		if instance != "" {
+			rs.balancer.observe(instance, time.Since(attemptStart), failed)
+		}
+
+		var pushErr callPushError
+		if errors.As(err, &pushErr) {
+			info.addAttempt(rs.info.Name, pushErr.err)
+
+			return VshardRouterCallResp{}, pushErr.err
+		}
+
 		if err != nil {
-			return VshardRouterCallResp{}, fmt.Errorf("got error on future.GetTyped(): %w", err)
+			err = fmt.Errorf("got error on future.GetTyped(): %w", err)
+			info.addAttempt(rs.info.Name, err)
+
+			if !isConnectionError(err) {
+				return VshardRouterCallResp{}, err
+			}
+
+			// this err will be returned to a caller in case of timeout
+			retryAttempt.ErrName = RetryErrNameConnection
+			retryAttempt.Elapsed, retryAttempt.Err = time.Since(requestStartTime), err
+
+			var ok bool
+			if nextRs, ok = retry("connection_error", retryAttempt); !ok {
+				return VshardRouterCallResp{}, err
+			}
+
+			continue
 		}
 
 		r.log().Debugf(ctx, "Got call result response data %+v", storageCallResponse)
 
 		if storageCallResponse.AssertError != nil {
-			return VshardRouterCallResp{}, fmt.Errorf("%s: %s failed: %+v", vshardStorageClientCall, fnc, storageCallResponse.AssertError)
+			err = newStorageCallAssertError(fnc, storageCallResponse.AssertError)
+			info.addAttempt(rs.info.Name, err)
+
+			return VshardRouterCallResp{}, err
 		}
 
 		if storageCallResponse.VshardError != nil {
 			vshardError := storageCallResponse.VshardError
+			info.addAttempt(rs.info.Name, vshardError)
+
+			var retryReason string
 
 			switch vshardError.Name {
 			case VShardErrNameWrongBucket, VShardErrNameBucketIsLocked, VShardErrNameTransferIsInProgress:
 				// We reproduce here behavior in https://github.com/tarantool/vshard/blob/0.1.34/vshard/router/init.lua#L667
 				r.BucketReset(bucketID)
+				r.adaptiveDiscovery.wrongBucket()
 
-				destination := vshardError.Destination
-				if destination != "" {
-					var loggedOnce bool
-					for {
-						nameToReplicasetRef := r.getNameToReplicaset()
-
-						// In some cases destination contains UUID (prior to tnt 3.x), in some cases it contains replicaset name.
-						// So, at this point we don't know what destination is: a name or an UUID.
-						// But we need a name to access values in nameToReplicasetRef map, so let's find it out.
-						var destinationName string
-
-						_, destinationExists := nameToReplicasetRef[destination]
-						if destinationExists {
-							destinationName = destination
-						} else {
-							// for older logic with uuid we must support backward compatibility
-							// if destination is uuid and not name, lets find it too
-							for rsName, rs := range nameToReplicasetRef {
-								if rs.info.UUID.String() == destination {
-									destinationExists = true
-									destinationName = rsName
-									break
-								}
-							}
-						}
-
-						if destinationExists {
-							_, err := r.BucketSet(bucketID, destinationName)
-							if err == nil {
-								break // breaks loop
-							}
+				if destination := vshardError.Destination; destination != "" {
+					destinationName, destinationExists := r.replicasetNameByDestination(destination)
+					if destinationExists {
+						if _, err := r.BucketSet(bucketID, destinationName); err != nil {
 							r.log().Warnf(ctx, "Failed set bucket %d to %v (possible race): %v", bucketID, destinationName, err)
+						} else {
+							retryAttempt.Destination = destinationName
 						}
-
-						if !loggedOnce {
-							r.log().Warnf(ctx, "Replicaset '%v' was not found, but received from storage as destination - please "+
-								"update configuration", destinationName)
-							loggedOnce = true
-						}
-
-						const defaultPoolingPause = 50 * time.Millisecond
-						time.Sleep(defaultPoolingPause)
-
-						if spent := time.Since(requestStartTime); spent > timeout {
-							return VshardRouterCallResp{}, vshardError
-						}
+					} else {
+						r.log().Warnf(ctx, "Replicaset '%v' was not found, but received from storage as destination - please "+
+							"update configuration", destination)
 					}
 				}
 
-				// retry for VShardErrNameWrongBucket, VShardErrNameBucketIsLocked, VShardErrNameTransferIsInProgress
-
-				r.metrics().RetryOnCall("bucket_migrate")
-
-				r.log().Debugf(ctx, "Retrying fnc '%s' cause got vshard error: %v", fnc, vshardError)
-
-				// this vshardError will be returned to a caller in case of timeout
-				err = vshardError
-				continue
+				retryReason = "bucket_migrate"
 			case VShardErrNameNonMaster:
 				// vshard.storage has returned NON_MASTER error, lua vshard router updates info about master in this case:
 				// See: https://github.com/tarantool/vshard/blob/b6fdbe950a2e4557f05b83bd8b846b126ec3724e/vshard/router/init.lua#L704.
-				// Since we use go-tarantool library, and go-tarantool library doesn't provide API to update info about current master,
-				// we just return this error as is.
-				return VshardRouterCallResp{}, vshardError
+				// go-tarantool pool detects a new master only periodically, so we remember the master reported by storage
+				// and send RW requests directly to it.
+				if !rs.updateMaster(vshardError.MasterUUID) {
+					r.log().Warnf(ctx, "Master '%s' of replicaset '%s' was not found, but received from storage - please "+
+						"update configuration", vshardError.MasterUUID, rs.info.Name)
+
+					return VshardRouterCallResp{}, vshardError
+				}
+
+				r.log().Warnf(ctx, "Replicaset '%s' master has changed to '%s'", rs.info.Name, vshardError.MasterUUID)
+
+				retryReason = "non_master"
 			default:
 				return VshardRouterCallResp{}, vshardError
 			}
+
+			// this vshardError will be returned to a caller in case of timeout
+			err = vshardError
+
+			retryAttempt.ErrName = vshardError.Name
+			retryAttempt.Elapsed, retryAttempt.Err = time.Since(requestStartTime), err
+
+			var ok bool
+			if nextRs, ok = retry(retryReason, retryAttempt); !ok {
+				return VshardRouterCallResp{}, err
+			}
+
+			continue
+		}
+
+		info.addAttempt(rs.info.Name, nil)
+
+		if mode == CallModeRW && opts.Session != nil {
+			r.observeWrite(ctx, rs, opts.Session)
 		}
 
 		r.metrics().RequestDuration(time.Since(requestStartTime), fnc, true, false)
@@ -407,6 +607,84 @@ func (r *Router) Call(ctx context.Context, bucketID uint64, mode CallMode,
 	}
 }
 
+// attemptTarget chooses the instance of the replicaset for an attempt of a call as Router.Call does:
+// a replica that has caught up with the session for reads of the session, or readTarget otherwise.
+// instance is empty if the pool chooses an instance of attemptPoolMode.
+func (r *Router) attemptTarget(ctx context.Context, rs *Replicaset, mode CallMode, poolMode pool.Mode,
+	session *Session) (attemptPoolMode pool.Mode, instance string, err error) {
+	if mode == CallModeRW {
+		return poolMode, "", nil
+	}
+
+	if token, ok := session.token(rs.info.Name); ok {
+		// if the chosen replica fails, the master has the writes of the session for sure
+		return pool.RW, r.caughtUpReplica(ctx, rs, token), nil
+	}
+
+	instance, err = rs.readTarget(poolMode)
+
+	return poolMode, instance, err
+}
+
+// callModeToPoolMode converts mode into pool mode and vshard mode (read or write) for vshard.storage.call.
+func callModeToPoolMode(mode CallMode) (pool.Mode, VshardMode, error) {
+	switch mode {
+	case CallModeRO:
+		return pool.RO, ReadMode, nil
+	case CallModeRW:
+		return pool.RW, WriteMode, nil
+	case CallModeRE:
+		// We can't use pool.PreferRO here, since go-tarantool always use balance=true politic,
+		// see: https://github.com/tarantool/go-tarantool/issues/400.
+		// So we ask for a replica and fall back to the master manually.
+		return pool.RO, ReadMode, nil
+	case CallModeBRO:
+		return pool.ANY, ReadMode, nil
+	case CallModeBRE:
+		return pool.PreferRO, ReadMode, nil
+	default:
+		return 0, "", fmt.Errorf("unknown VshardCallMode(%d)", mode)
+	}
+}
+
+func newVshardStorageCallRequest(ctx context.Context, bucketID uint64, vshardMode VshardMode,
+	fnc string, args interface{}) *tarantool.CallRequest {
+	return tarantool.NewCallRequest(vshardStorageClientCall).
+		Context(ctx).
+		Args([]interface{}{
+			bucketID,
+			vshardMode,
+			fnc,
+			args,
+		})
+}
+
+func newStorageCallAssertError(fnc string, assertError *assertError) error {
+	return fmt.Errorf("%s: %s failed: %+v", vshardStorageClientCall, fnc, assertError)
+}
+
+// replicasetNameByDestination finds the name of replicaset, that vshard storage has sent as destination.
+func (r *Router) replicasetNameByDestination(destination string) (string, bool) {
+	nameToReplicasetRef := r.getNameToReplicaset()
+
+	// In some cases destination contains UUID (prior to tnt 3.x), in some cases it contains replicaset name.
+	// So, at this point we don't know what destination is: a name or an UUID.
+	// But we need a name to access values in nameToReplicasetRef map, so let's find it out.
+	if _, ok := nameToReplicasetRef[destination]; ok {
+		return destination, true
+	}
+
+	// for older logic with uuid we must support backward compatibility
+	// if destination is uuid and not name, lets find it too
+	for rsName, rs := range nameToReplicasetRef {
+		if rs.info.UUID.String() == destination {
+			return rsName, true
+		}
+	}
+
+	return "", false
+}
+
 // CallRO is an alias for Call with CallModeRO.
 func (r *Router) CallRO(ctx context.Context, bucketID uint64,
 	fnc string, args interface{}, opts CallOpts) (VshardRouterCallResp, error) {
@@ -439,7 +717,7 @@ func (r *Router) CallBRE(ctx context.Context, bucketID uint64,
 
 // RouterMapCallRWOptions sets options for RouterMapCallRW.
 type RouterMapCallRWOptions struct {
-	// Timeout defines timeout for RouterMapCallRW.
+	// Timeout defines timeout for RouterMapCallRW, including retries of the ref stage.
 	Timeout time.Duration
 }
 
@@ -540,18 +818,9 @@ func (r *storageRefResponseProto) DecodeMsgpack(d *msgpack.Decoder) error {
 			return fmt.Errorf("protocol violation: length is %d on error case", respArrayLen)
 		}
 
-		// The possible variations of error here are fully unknown yet for us, e.g:
-		// vshard error, assert error or some other type of error. So this question requires research.
-		// So we do not decode it to some known error format, because we don't use it anyway.
-		decodedError, err := d.DecodeInterface()
-		if err != nil {
-			return err
-		}
-
-		// convert empty interface into error
-		r.err = fmt.Errorf("%v", decodedError)
+		r.err, err = decodeStorageRefError(d)
 
-		return nil
+		return err
 	}
 
 	r.bucketCount, err = d.DecodeUint64()
@@ -562,6 +831,33 @@ func (r *storageRefResponseProto) DecodeMsgpack(d *msgpack.Decoder) error {
 	return nil
 }
 
+// decodeStorageRefError decodes an error returned by storage_ref. Vshard errors (e.g. STORAGE_REF_ADD) are decoded
+// into StorageCallVShardError, so they can be told apart, other errors are converted into error as is.
+func decodeStorageRefError(d *msgpack.Decoder) (refErr error, err error) {
+	code, err := d.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	if msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32 {
+		var vshardError StorageCallVShardError
+
+		if err := d.Decode(&vshardError); err != nil {
+			return nil, fmt.Errorf("failed to decode storage ref error: %w", err)
+		}
+
+		return vshardError, nil
+	}
+
+	decodedError, err := d.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+
+	// convert empty interface into error
+	return fmt.Errorf("%v", decodedError), nil
+}
+
 type replicasetFuture struct {
 	// replicaset name
 	name   string
@@ -571,13 +867,39 @@ type replicasetFuture struct {
 // RouterMapCallRW is a consistent Map-Reduce. The given function is called on all masters in the
 // cluster with a guarantee that in case of success it was executed with all
 // buckets being accessible for reads and writes.
+// If buckets are being moved during the ref stage, the stage is retried until the timeout expires.
 // T is a return type of user defined function 'fnc'.
 // We define it as a distinct function, not a Router method, because golang limitations,
 // see: https://github.com/golang/go/issues/49085.
 func RouterMapCallRW[T any](r *Router, ctx context.Context,
 	fnc string, args interface{}, opts RouterMapCallRWOptions,
 ) (map[string]T, error) {
-	const vshardStorageServiceCall = "vshard.storage._call"
+	if len(r.cfg.Interceptors) == 0 {
+		return routerMapCallRW[T](r, ctx, fnc, args, opts, nil)
+	}
+
+	info := &CallInfo{
+		Mode:      CallModeRW,
+		MapReduce: true,
+		Fnc:       fnc,
+		Args:      args,
+	}
+
+	reply, err := r.intercept(ctx, info, func(ctx context.Context, info *CallInfo) (interface{}, error) {
+		return routerMapCallRW[T](r, ctx, info.Fnc, info.Args, opts, info)
+	})
+
+	return interceptedReply[map[string]T](reply, err)
+}
+
+// routerMapCallRW implements RouterMapCallRW, info is nil if there are no interceptors.
+func routerMapCallRW[T any](r *Router, ctx context.Context,
+	fnc string, args interface{}, opts RouterMapCallRWOptions, info *CallInfo,
+) (map[string]T, error) {
+	if err := r.beginRequest(); err != nil {
+		return nil, err
+	}
+	defer r.endRequest()
 
 	timeout := callTimeoutDefault
 	if opts.Timeout > 0 {
@@ -585,99 +907,201 @@ func RouterMapCallRW[T any](r *Router, ctx context.Context,
 	}
 
 	timeStart := time.Now()
-	refID := r.refID.Add(1)
 
 	nameToReplicasetRef := r.getNameToReplicaset()
 
-	defer func() {
-		// call function "storage_unref" if map_callrw is failed or successed
-		storageUnrefReq := tarantool.NewCallRequest(vshardStorageServiceCall).
-			Args([]interface{}{"storage_unref", refID})
-
-		for _, rs := range nameToReplicasetRef {
-			future := rs.conn.Do(storageUnrefReq, pool.RW)
-			future.SetError(nil) // TODO: does it cancel the request above or not?
-		}
-	}()
-
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	releaseLimits, err := acquireAllLimits(ctx, nameToReplicasetRef)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseLimits()
+
 	// ref stage
 
-	storageRefReq := tarantool.NewCallRequest(vshardStorageServiceCall).
-		Context(ctx).
-		Args([]interface{}{"storage_ref", refID, timeout})
+	refID, err := r.storageRefAllRetry(ctx, nameToReplicasetRef, info)
+	if err != nil {
+		return nil, err
+	}
+	defer r.storageUnrefAll(ctx, nameToReplicasetRef, refID)
 
-	var rsFutures = make([]replicasetFuture, 0, len(nameToReplicasetRef))
+	// map stage
 
-	// ref stage: send concurrent ref requests
-	for name, rs := range nameToReplicasetRef {
-		rsFutures = append(rsFutures, replicasetFuture{
-			name:   name,
-			future: rs.conn.Do(storageRefReq, pool.RW),
-		})
+	rsFutures := storageMapAll(ctx, nameToReplicasetRef, refID, fnc, args)
+
+	// map stage: get their responses
+	nameToResult := make(map[string]T)
+	for _, rsFuture := range rsFutures {
+		storageMapResponse := storageMapResponseProto[T]{}
+
+		err := getTyped(ctx, rsFuture.future, &storageMapResponse)
+		if err != nil {
+			err = fmt.Errorf("rs {%s} storage_map err: %v", rsFuture.name, err)
+			info.addAttempt(rsFuture.name, err)
+
+			return nil, err
+		}
+
+		if !storageMapResponse.ok {
+			err = fmt.Errorf("storage_map failed on %v: %+v", rsFuture.name, storageMapResponse.err)
+			info.addAttempt(rsFuture.name, err)
+
+			return nil, err
+		}
+
+		info.addAttempt(rsFuture.name, nil)
+
+		nameToResult[rsFuture.name] = storageMapResponse.value
 	}
 
+	r.metrics().RequestDuration(time.Since(timeStart), fnc, true, true)
+
+	return nameToResult, nil
+}
+
+// storageRefRetryPause is a pause before the next attempt of map-reduce ref stage.
+const storageRefRetryPause = 50 * time.Millisecond
+
+// storageRefAllRetry performs the ref stage of RouterMapCallRW with a new ref id for every attempt.
+// As lua vshard router does, the stage is retried until ctx is done if buckets are being moved:
+// the bucket counts don't add up, or a replicaset can't be referenced at the moment.
+// The ref of a failed attempt is released, the caller must release the returned one with storageUnrefAll.
+func (r *Router) storageRefAllRetry(ctx context.Context, nameToReplicasetRef map[string]*Replicaset,
+	info *CallInfo) (int64, error) {
+	var retryErr error
+
+	for {
+		refID := r.refID.Add(1)
+
+		retryReason, err := r.storageRefAll(ctx, nameToReplicasetRef, refID, info)
+		if err == nil {
+			return refID, nil
+		}
+
+		r.storageUnrefAll(ctx, nameToReplicasetRef, refID)
+
+		if retryReason == "" {
+			if retryErr != nil && ctx.Err() != nil {
+				// the last attempt has been interrupted by the deadline, the reason of retries is more useful
+				return 0, fmt.Errorf("%w (ref stage has been retried until %w)", retryErr, ctx.Err())
+			}
+
+			return 0, err
+		}
+
+		retryErr = err
+
+		r.log().Debugf(ctx, "Retry map-reduce ref stage on %s: %v", retryReason, err)
+
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("%w (ref stage has been retried until %w)", err, ctx.Err())
+		case <-time.After(storageRefRetryPause):
+		}
+
+		r.metrics().RetryOnCall(retryReason)
+	}
+}
+
+// storageRefAll performs an attempt of the ref stage of RouterMapCallRW: it refs buckets on all the replicasets
+// and checks that all the buckets have been referenced. retryReason is not empty if the attempt can be retried.
+func (r *Router) storageRefAll(ctx context.Context, nameToReplicasetRef map[string]*Replicaset,
+	refID int64, info *CallInfo) (retryReason string, err error) {
+	rsFutures := storageRefSendAll(ctx, nameToReplicasetRef, refID)
+
 	// ref stage: get their responses
 	var totalBucketCount uint64
-	// proto for 'storage_ref' method:
-	// https://github.com/tarantool/vshard/blob/dfa2cc8a2aff221d5f421298851a9a229b2e0434/vshard/storage/init.lua#L3137
-	for _, rsFuture := range rsFutures {
-		var storageRefResponse storageRefResponseProto
 
-		if err := rsFuture.future.GetTyped(&storageRefResponse); err != nil {
-			return nil, fmt.Errorf("rs {%s} storage_ref err: %v", rsFuture.name, err)
-		}
+	for _, rsFuture := range rsFutures {
+		bucketCount, retryReason, err := storageRefWait(ctx, rsFuture)
+		if err != nil {
+			info.addAttempt(rsFuture.name, err)
 
-		if storageRefResponse.err != nil {
-			return nil, fmt.Errorf("storage_ref failed on %v: %v", rsFuture.name, storageRefResponse.err)
+			return retryReason, err
 		}
 
-		totalBucketCount += storageRefResponse.bucketCount
+		totalBucketCount += bucketCount
 	}
 
 	if totalBucketCount != r.cfg.TotalBucketCount {
-		return nil, fmt.Errorf("total bucket count got %d, expected %d", totalBucketCount, r.cfg.TotalBucketCount)
+		return "bucket_count_mismatch",
+			fmt.Errorf("total bucket count got %d, expected %d", totalBucketCount, r.cfg.TotalBucketCount)
 	}
 
-	// map stage
+	return "", nil
+}
 
-	storageMapReq := tarantool.NewCallRequest(vshardStorageServiceCall).
+// storageRefSendAll sends the ref stage requests of map-reduce to the replicasets.
+func storageRefSendAll(ctx context.Context, nameToReplicasetRef map[string]*Replicaset,
+	refID int64) []replicasetFuture {
+	// the ref lives on storages until the deadline if it's not released or used by storage_map
+	deadline, _ := ctx.Deadline()
+
+	storageRefReq := tarantool.NewCallRequest(vshardStorageServiceCall).
 		Context(ctx).
-		Args([]interface{}{"storage_map", refID, fnc, args})
+		Args([]interface{}{"storage_ref", refID, time.Until(deadline).Seconds()})
 
-	// reuse the same slice again
-	rsFutures = rsFutures[0:0]
+	rsFutures := make([]replicasetFuture, 0, len(nameToReplicasetRef))
 
-	// map stage: send concurrent map requests
+	// ref stage: send concurrent ref requests
 	for name, rs := range nameToReplicasetRef {
 		rsFutures = append(rsFutures, replicasetFuture{
 			name:   name,
-			future: rs.conn.Do(storageMapReq, pool.RW),
+			future: rs.do(storageRefReq, pool.RW),
 		})
 	}
 
-	// map stage: get their responses
-	nameToResult := make(map[string]T)
-	for _, rsFuture := range rsFutures {
-		storageMapResponse := storageMapResponseProto[T]{}
+	return rsFutures
+}
 
-		err := rsFuture.future.GetTyped(&storageMapResponse)
-		if err != nil {
-			return nil, fmt.Errorf("rs {%s} storage_map err: %v", rsFuture.name, err)
-		}
+// storageRefWait waits for the ref stage response of the replicaset and returns the number of referenced buckets.
+// retryReason is not empty if the replicaset can't be referenced at the moment, but the ref can be retried.
+func storageRefWait(ctx context.Context, rsFuture replicasetFuture) (bucketCount uint64, retryReason string, err error) {
+	var storageRefResponse storageRefResponseProto
 
-		if !storageMapResponse.ok {
-			return nil, fmt.Errorf("storage_map failed on %v: %+v", rsFuture.name, storageMapResponse.err)
+	// proto for 'storage_ref' method:
+	// https://github.com/tarantool/vshard/blob/dfa2cc8a2aff221d5f421298851a9a229b2e0434/vshard/storage/init.lua#L3137
+	if err := getTyped(ctx, rsFuture.future, &storageRefResponse); err != nil {
+		return 0, "", fmt.Errorf("rs {%s} storage_ref err: %w", rsFuture.name, err)
+	}
+
+	if storageRefResponse.err != nil {
+		err := fmt.Errorf("storage_ref failed on %v: %w", rsFuture.name, storageRefResponse.err)
+
+		var vshardError StorageCallVShardError
+		if errors.As(storageRefResponse.err, &vshardError) {
+			switch vshardError.Name {
+			case VShardErrNameStorageRefAdd, VShardErrNameStorageIsReferenced:
+				return 0, strings.ToLower(vshardError.Name), err
+			}
 		}
 
-		nameToResult[rsFuture.name] = storageMapResponse.value
+		return 0, "", err
 	}
 
-	r.metrics().RequestDuration(time.Since(timeStart), fnc, true, true)
+	return storageRefResponse.bucketCount, "", nil
+}
 
-	return nameToResult, nil
+// storageMapAll sends the map stage requests of RouterMapCallRW to all the replicasets.
+func storageMapAll(ctx context.Context, nameToReplicasetRef map[string]*Replicaset,
+	refID int64, fnc string, args interface{}) []replicasetFuture {
+	storageMapReq := tarantool.NewCallRequest(vshardStorageServiceCall).
+		Context(ctx).
+		Args([]interface{}{"storage_map", refID, fnc, args})
+
+	rsFutures := make([]replicasetFuture, 0, len(nameToReplicasetRef))
+
+	// map stage: send concurrent map requests
+	for name, rs := range nameToReplicasetRef {
+		rsFutures = append(rsFutures, replicasetFuture{
+			name:   name,
+			future: rs.do(storageMapReq, pool.RW),
+		})
+	}
+
+	return rsFutures
 }
 
 // RouteAll return map of all replicasets.
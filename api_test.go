@@ -2,11 +2,22 @@ package vshard_router // nolint: revive
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
 	"github.com/vmihailenco/msgpack/v5"
 	"github.com/vmihailenco/msgpack/v5/msgpcode"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
 )
 
 func TestVshardMode_String_NotEmpty(t *testing.T) {
@@ -209,6 +220,43 @@ func TestVshardStorageCallResponseProto_DecodeMsgpack_GetNonTyped(t *testing.T)
 	}
 }
 
+func TestVshardStorageCallResponseProto_DecodeMsgpack_ManyValues(t *testing.T) {
+	t.Parallel()
+
+	for _, count := range []int{0, 1, 15, 16, math.MaxUint16, math.MaxUint16 + 1} {
+		t.Run(fmt.Sprint(count), func(t *testing.T) {
+			t.Parallel()
+
+			values := make([]interface{}, 0, count+1)
+			values = append(values, true)
+
+			for i := 0; i < count; i++ {
+				values = append(values, int8(i%100))
+			}
+
+			bts, err := msgpack.Marshal(values)
+			require.NoError(t, err)
+
+			// the size of the rest is known for bytes.Reader, but not for a reader wrapped by msgpack into bufio.Reader
+			for _, reader := range []io.Reader{bytes.NewReader(bts), struct{ io.Reader }{bytes.NewReader(bts)}} {
+				protoResp := vshardStorageCallResponseProto{}
+
+				err = protoResp.DecodeMsgpack(msgpack.NewDecoder(reader))
+				require.NoError(t, err)
+
+				var result []int8
+				err = protoResp.CallResp.GetTyped(&result)
+				require.NoError(t, err)
+				require.Len(t, result, count)
+
+				for i, value := range result {
+					require.Equal(t, int8(i%100), value)
+				}
+			}
+		})
+	}
+}
+
 func BenchmarkVshardStorageCallResponseProto_DecodeMsgpack_Ok(b *testing.B) {
 	// Skip in timer buffer creation information
 	b.StopTimer()
@@ -261,3 +309,263 @@ func BenchmarkVshardStorageCallResponseProto_DecodeMsgpack_Ok(b *testing.B) {
 	require.True(b, errCount == 0)
 	b.ReportAllocs()
 }
+
+// newCallResponseFuture creates a future that is resolved with IPROTO_DATA body containing data.
+func newCallResponseFuture(t testing.TB, data interface{}) *tarantool.Future {
+	future := tarantool.NewFuture(tarantool.NewCallRequest("vshard.storage.call"))
+	setCallResponse(t, future, data)
+
+	return future
+}
+
+// newCallResponseFunc returns a mock return function creating a new future with a call response with data
+// for every request, so the futures of concurrent requests are not shared.
+func newCallResponseFunc(t testing.TB, data interface{}) func(tarantool.Request, pool.Mode) *tarantool.Future {
+	return func(_ tarantool.Request, _ pool.Mode) *tarantool.Future {
+		return newCallResponseFuture(t, data)
+	}
+}
+
+// setCallResponse resolves the future with a call response with data.
+func setCallResponse(t testing.TB, future *tarantool.Future, data interface{}) {
+	const iprotoData = 0x30
+
+	bts, err := msgpack.Marshal(map[int]interface{}{iprotoData: data})
+	require.NoError(t, err)
+
+	err = future.SetResponse(tarantool.Header{}, bytes.NewReader(bts))
+	require.NoError(t, err)
+}
+
+// newErrorFuture creates a future that is resolved with err.
+func newErrorFuture(err error) *tarantool.Future {
+	future := tarantool.NewFuture(tarantool.NewCallRequest("vshard.storage.call"))
+	future.SetError(err)
+
+	return future
+}
+
+// instancePoolerMock is a mock of Pooler that can send requests to the specific instance
+// as pool.ConnectionPool does, see instancePooler.
+type instancePoolerMock struct {
+	*mockpool.Pooler
+}
+
+func newInstancePoolerMock(t *testing.T) instancePoolerMock {
+	return instancePoolerMock{Pooler: mockpool.NewPooler(t)}
+}
+
+func (m instancePoolerMock) DoInstance(req tarantool.Request, name string) *tarantool.Future {
+	ret := m.MethodCalled("DoInstance", req, name)
+
+	if rf, ok := ret.Get(0).(func(tarantool.Request, string) *tarantool.Future); ok {
+		return rf(req, name)
+	}
+
+	return ret.Get(0).(*tarantool.Future)
+}
+
+// newTestRouter creates a router with a single replicaset that owns every bucket.
+func newTestRouter(conn Pooler, instances ...InstanceInfo) *Router {
+	router := &Router{
+		cfg: Config{
+			TotalBucketCount: uint64(10),
+			Loggerf:          emptyLogfProvider,
+			Metrics:          emptyMetricsProvider,
+		},
+	}
+	router.setEmptyRouteMap()
+	_ = router.swapNameToReplicaset(nil, &map[string]*Replicaset{
+		"replicaset_1": newReplicaset(ReplicasetInfo{Name: "replicaset_1"}, conn, instances),
+	})
+
+	for bucketID := uint64(1); bucketID <= router.cfg.TotalBucketCount; bucketID++ {
+		_, _ = router.BucketSet(bucketID, "replicaset_1")
+	}
+
+	return router
+}
+
+func TestRouter_Call_CallModeRE(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("no replicas fallback to master", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).Return(newErrorFuture(pool.ErrNoRoInstance))
+		mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{true, "master"}))
+
+		router := newTestRouter(mPool)
+
+		resp, err := router.CallRE(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+
+		var result string
+		require.NoError(t, resp.GetTyped(&[]interface{}{&result}))
+		require.Equal(t, "master", result)
+	})
+
+	t.Run("retry on connection error", func(t *testing.T) {
+		t.Parallel()
+
+		connErr := tarantool.ClientError{Code: tarantool.ErrConnectionClosed, Msg: "connection closed"}
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).Return(newErrorFuture(connErr)).Once()
+		mPool.On("Do", mock.Anything, pool.RO).Return(newCallResponseFuture(t, []interface{}{true, "replica"})).Once()
+
+		router := newTestRouter(mPool)
+
+		resp, err := router.CallRE(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+
+		var result string
+		require.NoError(t, resp.GetTyped(&[]interface{}{&result}))
+		require.Equal(t, "replica", result)
+	})
+
+	t.Run("connection error until timeout", func(t *testing.T) {
+		t.Parallel()
+
+		connErr := tarantool.ClientError{Code: tarantool.ErrConnectionNotReady, Msg: "connection not ready"}
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).Return(newErrorFuture(connErr))
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRE(ctx, 1, "echo", []interface{}{}, CallOpts{Timeout: 100 * time.Millisecond})
+		require.ErrorIs(t, err, connErr)
+	})
+
+	t.Run("other errors are not retried", func(t *testing.T) {
+		t.Parallel()
+
+		otherErr := fmt.Errorf("some error")
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).Return(newErrorFuture(otherErr)).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRE(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.ErrorIs(t, err, otherErr)
+	})
+}
+
+func TestRouter_Call_NonMaster(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	const newMasterName = "instance_2"
+
+	newMasterUUID := uuid.New()
+
+	nonMasterErr := func(master string) StorageCallVShardError {
+		return StorageCallVShardError{
+			Name:       VShardErrNameNonMaster,
+			Code:       VShardErrCodeNonMaster,
+			MasterUUID: master,
+		}
+	}
+
+	t.Run("retry on the new master by uuid", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, nonMasterErr(newMasterUUID.String())})).Once()
+		mPool.On("DoInstance", mock.Anything, newMasterName).
+			Return(func(_ tarantool.Request, _ string) *tarantool.Future {
+				return newCallResponseFuture(t, []interface{}{true, "ok"})
+			}).Twice()
+
+		router := newTestRouter(mPool,
+			InstanceInfo{Name: "instance_1", UUID: uuid.New()},
+			InstanceInfo{Name: newMasterName, UUID: newMasterUUID},
+		)
+
+		resp, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+
+		result, err := resp.Get()
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"ok"}, result)
+
+		// the next request is sent to the new master directly
+		_, err = router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+
+	t.Run("retry on the new master by name", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, nonMasterErr(newMasterName)})).Once()
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{newMasterName: {}})
+		mPool.On("DoInstance", mock.Anything, newMasterName).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+
+	t.Run("unknown master", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, nonMasterErr("unknown")})).Once()
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{})
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+
+		var vshardError *StorageCallVShardError
+		require.ErrorAs(t, err, &vshardError)
+		require.Equal(t, VShardErrNameNonMaster, vshardError.Name)
+	})
+
+	t.Run("fallback to pool when master is unreachable", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, nonMasterErr(newMasterName)})).Once()
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{newMasterName: {}})
+		mPool.On("DoInstance", mock.Anything, newMasterName).
+			Return(newErrorFuture(pool.ErrNoHealthyInstance)).Once()
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+
+	t.Run("pooler without DoInstance", func(t *testing.T) {
+		t.Parallel()
+
+		// the request is retried according to pool mode
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, nonMasterErr(newMasterName)})).Once()
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{newMasterName: {}})
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,161 @@
+package vshard_router //nolint:revive
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	balancerAlphaDefault        = 0.2
+	balancerErrorPenaltyDefault = 1 * time.Second
+	balancerDecayTimeDefault    = 10 * time.Second
+)
+
+// BalancerOpts enables latency-aware balancing of CallModeBRO and CallModeBRE requests.
+// The router keeps an exponentially weighted moving average (EWMA) of response time and error rate
+// of each instance, and chooses the instance with the lower score among two random ones (power of two choices).
+// The score of an instance is its average response time plus its error rate multiplied by ErrorPenalty.
+// The score of an instance that has not been used for a while fades out, so it gets requests again.
+type BalancerOpts struct {
+	// Alpha (0, 1] is a weight of the new response in the average. Default is 0.2.
+	Alpha float64
+	// ErrorPenalty is added to the score of an instance whose every request fails. Default is 1s.
+	ErrorPenalty time.Duration
+	// DecayTime is a time it takes the score of an idle instance to fade out e times. Default is 10s.
+	DecayTime time.Duration
+}
+
+// instanceStats are EWMA statistics of a single instance.
+type instanceStats struct {
+	latency   float64 // seconds
+	errorRate float64
+	updated   time.Time
+}
+
+// balancer chooses instances of a replicaset for balanced read requests, nil balancer chooses a random instance.
+type balancer struct {
+	opts    BalancerOpts
+	onScore func(instance string, latency time.Duration, errorRate, score float64)
+
+	mutex sync.Mutex
+	stats map[string]*instanceStats
+}
+
+func newBalancer(opts BalancerOpts, onScore func(instance string, latency time.Duration, errorRate, score float64)) *balancer {
+	if opts.Alpha <= 0 || opts.Alpha > 1 {
+		opts.Alpha = balancerAlphaDefault
+	}
+
+	if opts.ErrorPenalty <= 0 {
+		opts.ErrorPenalty = balancerErrorPenaltyDefault
+	}
+
+	if opts.DecayTime <= 0 {
+		opts.DecayTime = balancerDecayTimeDefault
+	}
+
+	return &balancer{
+		opts:    opts,
+		onScore: onScore,
+		stats:   make(map[string]*instanceStats),
+	}
+}
+
+// scoreLocked must be called with mutex locked. Unknown instances have the best score, so they are tried first.
+func (b *balancer) scoreLocked(instance string, now time.Time) float64 {
+	stats, ok := b.stats[instance]
+	if !ok {
+		return 0
+	}
+
+	score := stats.latency + stats.errorRate*b.opts.ErrorPenalty.Seconds()
+	idle := now.Sub(stats.updated)
+
+	return score * math.Exp(-idle.Seconds()/b.opts.DecayTime.Seconds())
+}
+
+// choose returns one of instances using power of two choices, instances must not be empty.
+func (b *balancer) choose(instances []string) string {
+	if b == nil || len(instances) == 1 {
+		//nolint:gosec
+		return instances[rand.Intn(len(instances))]
+	}
+
+	//nolint:gosec
+	i := rand.Intn(len(instances))
+	//nolint:gosec
+	j := rand.Intn(len(instances) - 1)
+	if j >= i {
+		j++
+	}
+
+	now := time.Now()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.scoreLocked(instances[j], now) < b.scoreLocked(instances[i], now) {
+		return instances[j]
+	}
+
+	return instances[i]
+}
+
+// observe updates statistics of the instance with the response time of the request,
+// failed is true if the request has failed due to connection error or timeout.
+func (b *balancer) observe(instance string, latency time.Duration, failed bool) {
+	if b == nil {
+		return
+	}
+
+	var errorSample float64
+	if failed {
+		errorSample = 1
+	}
+
+	now := time.Now()
+
+	b.mutex.Lock()
+
+	stats, ok := b.stats[instance]
+	if !ok {
+		stats = &instanceStats{errorRate: errorSample}
+		if !failed {
+			stats.latency = latency.Seconds()
+		}
+
+		b.stats[instance] = stats
+	} else {
+		alpha := b.opts.Alpha
+		if !failed {
+			// the response time of a failed request says nothing about the instance speed
+			stats.latency += alpha * (latency.Seconds() - stats.latency)
+		}
+
+		stats.errorRate += alpha * (errorSample - stats.errorRate)
+	}
+
+	stats.updated = now
+
+	avgLatency, errorRate, score := stats.latency, stats.errorRate, b.scoreLocked(instance, now)
+
+	b.mutex.Unlock()
+
+	if b.onScore != nil {
+		b.onScore(instance, time.Duration(avgLatency*float64(time.Second)), errorRate, score)
+	}
+}
+
+// forget drops statistics of the removed instance.
+func (b *balancer) forget(instance string) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.stats, instance)
+}
@@ -0,0 +1,130 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+func TestBalancer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("latency", func(t *testing.T) {
+		t.Parallel()
+
+		var scores []string
+
+		b := newBalancer(BalancerOpts{}, func(instance string, _ time.Duration, _, _ float64) {
+			scores = append(scores, instance)
+		})
+
+		b.observe("slow", 100*time.Millisecond, false)
+		b.observe("fast", time.Millisecond, false)
+
+		require.Equal(t, []string{"slow", "fast"}, scores)
+
+		for i := 0; i < 10; i++ {
+			require.Equal(t, "fast", b.choose([]string{"slow", "fast"}))
+		}
+
+		// unknown instances are tried first
+		require.Equal(t, "new", b.choose([]string{"fast", "new"}))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBalancer(BalancerOpts{Alpha: 0.5, ErrorPenalty: time.Second}, nil)
+
+		b.observe("failing", time.Millisecond, false)
+		b.observe("failing", time.Second, true)
+		b.observe("slow", 100*time.Millisecond, false)
+
+		b.mutex.Lock()
+		stats := *b.stats["failing"]
+		b.mutex.Unlock()
+
+		// the response time of the failed request is ignored
+		require.InDelta(t, 0.001, stats.latency, 1e-9)
+		require.InDelta(t, 0.5, stats.errorRate, 1e-9)
+
+		require.Equal(t, "slow", b.choose([]string{"failing", "slow"}))
+	})
+
+	t.Run("decay", func(t *testing.T) {
+		t.Parallel()
+
+		b := newBalancer(BalancerOpts{DecayTime: time.Second}, nil)
+
+		b.observe("idle", time.Second, true)
+		b.observe("busy", 100*time.Millisecond, false)
+
+		b.mutex.Lock()
+		b.stats["idle"].updated = time.Now().Add(-time.Minute)
+		b.mutex.Unlock()
+
+		require.Equal(t, "idle", b.choose([]string{"idle", "busy"}))
+
+		b.forget("idle")
+
+		b.mutex.Lock()
+		require.NotContains(t, b.stats, "idle")
+		b.mutex.Unlock()
+	})
+}
+
+func TestRouter_Call_Balancer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	instances := map[string]pool.ConnectionInfo{
+		"master":  {ConnectedNow: true, ConnRole: pool.MasterRole},
+		"replica": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+	}
+
+	t.Run("BRO", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(instances)
+		mPool.On("DoInstance", mock.Anything, "master").
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.balancer = newBalancer(BalancerOpts{}, nil)
+		rs.balancer.observe("replica", time.Second, false)
+
+		_, err := router.CallBRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+
+		rs.balancer.mutex.Lock()
+		require.Contains(t, rs.balancer.stats, "master")
+		rs.balancer.mutex.Unlock()
+	})
+
+	t.Run("BRE", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(instances)
+		mPool.On("DoInstance", mock.Anything, "replica").
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.balancer = newBalancer(BalancerOpts{}, nil)
+		rs.balancer.observe("replica", time.Second, false)
+
+		// the master is not used while there are replicas
+		_, err := router.CallBRE(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,325 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+// CallBatchItem is a single request of Router.CallBatch.
+type CallBatchItem struct {
+	// BucketID is a bucket identifier the request is routed by.
+	BucketID uint64
+	// Args are arguments of the user defined function for this item.
+	Args interface{}
+}
+
+// CallBatchResult is a result of a single request of Router.CallBatch.
+type CallBatchResult struct {
+	Resp VshardRouterCallResp
+	Err  error
+}
+
+// batchGroup is an attempt of the batch items routed to the same replicaset. The group is sent to the same
+// instance and takes a single slot of Config.Limits and a single circuit breaker probe, as a single call does.
+type batchGroup struct {
+	rs    *Replicaset
+	items []int
+	// futures are in-flight requests of items, they are nil if err is set
+	futures []*tarantool.Future
+	// instance is an instance chosen by the router, it is empty if the pool chooses an instance
+	instance string
+	poolMode pool.Mode
+	// err fails all the items of the group without sending them
+	err           error
+	probe         bool
+	releaseLimits func()
+	start         time.Time
+}
+
+// CallBatch calls the function 'fnc' for every item on the shard storing the bucket of the item.
+// Items are routed with Router.Route and requests to all replicasets are sent concurrently, a request per item.
+// The items of a replicaset are sent as a single call would be: to the same instance chosen by the mode,
+// CallOpts.Session and Config options (ReplicationHealth, Zone, Balancer), through a single slot of
+// Config.Limits and a single check of the circuit breaker.
+// The result contains a response or an error for each item, in the same order as items.
+// The failed items are retried by the same rules as Router.Call does (see CallOpts.RetryPolicy),
+// the items that have succeeded are not sent again. The next round of retries starts after
+// the longest delay chosen by the retry policy for the items of the round.
+// The returned error is not nil only if the whole batch can't be performed (e.g. unknown mode).
+// Config.Interceptors are not called for CallBatch.
+func (r *Router) CallBatch(ctx context.Context, mode CallMode, fnc string,
+	items []CallBatchItem, opts CallOpts) ([]CallBatchResult, error) {
+	if err := r.beginRequest(); err != nil {
+		return nil, err
+	}
+	defer r.endRequest()
+
+	poolMode, vshardMode, err := callModeToPoolMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := callTimeoutDefault
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	retryPolicy := r.retryPolicy(opts)
+
+	requestStartTime := time.Now()
+
+	results := make([]CallBatchResult, len(items))
+	pending := make([]int, 0, len(items))
+
+	for idx, item := range items {
+		if item.BucketID < 1 || r.cfg.TotalBucketCount < item.BucketID {
+			results[idx].Err = fmt.Errorf("bucket id is out of range: %d (total %d)", item.BucketID, r.cfg.TotalBucketCount)
+			continue
+		}
+
+		pending = append(pending, idx)
+	}
+
+	// attempts is the number of failed attempts of every item
+	attempts := make([]int, len(items))
+	// idxToNextRs keeps replicasets chosen by retry policy for the next attempt of items
+	idxToNextRs := make(map[int]*Replicaset)
+
+	for len(pending) > 0 {
+		var retry []int
+		var retryDelay time.Duration
+
+		// retryItem asks retry policy whether the failed item should be retried,
+		// the error of the item is returned to a caller otherwise.
+		retryItem := func(idx int, reason string, retryAttempt RetryAttempt) {
+			attempts[idx]++
+
+			retryAttempt.Attempt = attempts[idx]
+			retryAttempt.BucketID = items[idx].BucketID
+			retryAttempt.Mode = mode
+			retryAttempt.Elapsed, retryAttempt.Err = time.Since(requestStartTime), results[idx].Err
+
+			decision, ok := r.retryDecision(ctx, retryPolicy, reason, retryAttempt, timeout)
+			if !ok {
+				return
+			}
+
+			if rs := r.retryReplicaset(ctx, decision, retryAttempt.BucketID); rs != nil {
+				idxToNextRs[idx] = rs
+			}
+
+			retryDelay = max(retryDelay, decision.Delay)
+			retry = append(retry, idx)
+		}
+
+		// group items by replicaset
+		rsToItems := make(map[*Replicaset][]int)
+
+		for _, idx := range pending {
+			bucketID := items[idx].BucketID
+
+			rs, ok := idxToNextRs[idx]
+			delete(idxToNextRs, idx)
+
+			if !ok {
+				var err error
+
+				rs, err = r.Route(ctx, bucketID)
+				if err != nil {
+					// this error will be returned to a caller in case of timeout
+					results[idx].Err = fmt.Errorf("cant resolve bucket %d: %w", bucketID, err)
+
+					var retryAttempt RetryAttempt
+
+					var vshardError *StorageCallVShardError
+					if errors.As(err, &vshardError) {
+						retryAttempt.ErrName = vshardError.Name
+					}
+
+					retryItem(idx, "bucket_resolve_error", retryAttempt)
+
+					continue
+				}
+			}
+
+			rsToItems[rs] = append(rsToItems[rs], idx)
+		}
+
+		// send requests to all replicasets concurrently
+		groups := make([]*batchGroup, 0, len(rsToItems))
+
+		for rs, rsItems := range rsToItems {
+			group := &batchGroup{rs: rs, items: rsItems}
+			groups = append(groups, group)
+
+			var err error
+
+			group.releaseLimits, err = rs.acquireLimits(ctx, mode)
+			if err != nil {
+				for _, idx := range rsItems {
+					results[idx].Err = err
+				}
+
+				continue
+			}
+
+			probe, allowed, lastErr := rs.breaker.allow()
+			if !allowed {
+				rs.abortLimits(mode)
+
+				for _, idx := range rsItems {
+					results[idx].Err = newVShardErrorReplicasetInBackoff(rs.info, lastErr)
+				}
+
+				continue
+			}
+
+			group.probe = probe
+
+			group.poolMode, group.instance, group.err = r.attemptTarget(ctx, rs, mode, poolMode, opts.Session)
+			if group.err != nil && mode == CallModeRE {
+				// There are no available replicas, so fall back to the master as lua vshard router does.
+				group.poolMode, group.err = pool.RW, nil
+			}
+
+			r.log().Infof(ctx, "Try call %s on replicaset %s for %d buckets", fnc, rs.info.Name, len(rsItems))
+
+			group.start = time.Now()
+
+			if group.err != nil {
+				continue
+			}
+
+			group.futures = make([]*tarantool.Future, 0, len(rsItems))
+
+			for _, idx := range rsItems {
+				tntReq := newVshardStorageCallRequest(ctx, items[idx].BucketID, vshardMode, fnc, items[idx].Args)
+
+				group.futures = append(group.futures, rs.doInstance(tntReq, group.instance, group.poolMode))
+			}
+		}
+
+		// wait for their responses
+		for _, group := range groups {
+			if group.futures == nil && group.err == nil {
+				// the group has been rejected by limits or circuit breaker
+				continue
+			}
+
+			rs := group.rs
+
+			var groupErr error
+			var succeeded bool
+
+			for i, idx := range group.items {
+				bucketID := items[idx].BucketID
+
+				retryAttempt := RetryAttempt{Replicaset: rs.info.Name}
+
+				storageCallResponse := vshardStorageCallResponseProto{}
+
+				send := func(instance string, mode pool.Mode) error {
+					tntReq := newVshardStorageCallRequest(ctx, bucketID, vshardMode, fnc, items[idx].Args)
+
+					return rs.doInstance(tntReq, instance, mode).GetTyped(&storageCallResponse)
+				}
+
+				err := group.err
+				if err == nil {
+					err = group.futures[i].GetTyped(&storageCallResponse)
+				}
+
+				if err != nil && group.instance != "" && isConnectionError(err) {
+					// the instance chosen by the router has failed, let the pool choose another one as Router.Call does
+					err = send("", group.poolMode)
+				}
+
+				if err != nil && mode == CallModeRE && errors.Is(err, pool.ErrNoRoInstance) {
+					// There are no available replicas, so fall back to the master as lua vshard router does.
+					err = send("", pool.RW)
+				}
+
+				if groupErr == nil && err != nil && (isTransportError(err) || errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+					groupErr = err
+				}
+
+				switch {
+				case err != nil:
+					results[idx].Err = fmt.Errorf("got error on future.GetTyped(): %w", err)
+
+					if isConnectionError(err) {
+						retryAttempt.ErrName = RetryErrNameConnection
+						retryItem(idx, "connection_error", retryAttempt)
+					}
+				case storageCallResponse.AssertError != nil:
+					results[idx].Err = newStorageCallAssertError(fnc, storageCallResponse.AssertError)
+				case storageCallResponse.VshardError != nil:
+					vshardError := storageCallResponse.VshardError
+					results[idx].Err = vshardError
+					retryAttempt.ErrName = vshardError.Name
+
+					switch vshardError.Name {
+					case VShardErrNameWrongBucket, VShardErrNameBucketIsLocked, VShardErrNameTransferIsInProgress:
+						r.BucketReset(bucketID)
+						r.adaptiveDiscovery.wrongBucket()
+
+						if destination := vshardError.Destination; destination != "" {
+							if destinationName, ok := r.replicasetNameByDestination(destination); ok {
+								if _, err := r.BucketSet(bucketID, destinationName); err == nil {
+									retryAttempt.Destination = destinationName
+								}
+							}
+						}
+
+						retryItem(idx, "bucket_migrate", retryAttempt)
+					case VShardErrNameNonMaster:
+						if rs.updateMaster(vshardError.MasterUUID) {
+							retryItem(idx, "non_master", retryAttempt)
+						}
+					}
+				default:
+					results[idx] = CallBatchResult{Resp: storageCallResponse.CallResp}
+					succeeded = true
+				}
+			}
+
+			group.releaseLimits()
+			rs.breaker.done(group.probe, groupErr)
+
+			if group.instance != "" {
+				rs.balancer.observe(group.instance, time.Since(group.start), groupErr != nil)
+			}
+
+			if succeeded && mode == CallModeRW && opts.Session != nil {
+				r.observeWrite(ctx, rs, opts.Session)
+			}
+		}
+
+		pending = retry
+
+		if len(pending) > 0 && !retryPause(ctx, retryDelay) {
+			// the items keep the errors of their last attempts
+			break
+		}
+	}
+
+	ok := true
+	for _, result := range results {
+		if result.Err != nil {
+			ok = false
+			break
+		}
+	}
+
+	r.metrics().RequestDuration(time.Since(requestStartTime), fnc, ok, false)
+
+	return results, nil
+}
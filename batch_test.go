@@ -0,0 +1,221 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+func TestRouter_CallBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).Return(func(_ tarantool.Request, _ pool.Mode) *tarantool.Future {
+			return newCallResponseFuture(t, []interface{}{true, "ok"})
+		})
+
+		router := newTestRouter(mPool)
+
+		results, err := router.CallBatch(ctx, CallModeRW, "echo", []CallBatchItem{
+			{BucketID: 1, Args: []interface{}{}},
+			{BucketID: 2, Args: []interface{}{}},
+			{BucketID: 100500, Args: []interface{}{}},
+		}, CallOpts{})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		for _, result := range results[:2] {
+			require.NoError(t, result.Err)
+
+			resp, err := result.Resp.Get()
+			require.NoError(t, err)
+			require.Equal(t, []interface{}{"ok"}, resp)
+		}
+
+		require.Error(t, results[2].Err, "bucket id is out of range")
+	})
+
+	t.Run("retry only wrong bucket items", func(t *testing.T) {
+		t.Parallel()
+
+		wrongBucketErr := StorageCallVShardError{
+			BucketID:    1,
+			Name:        VShardErrNameWrongBucket,
+			Code:        VShardErrCodeWrongBucket,
+			Destination: "replicaset_1",
+		}
+		otherErr := StorageCallVShardError{
+			BucketID: 2,
+			Name:     VShardErrNameStorageIsDisabled,
+			Code:     VShardErrCodeStorageIsDisabled,
+		}
+
+		mPool := mockpool.NewPooler(t)
+		// the first round: both items fail, the second round: only the first item is retried
+		mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Once()
+		mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{nil, otherErr})).Once()
+		mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		results, err := router.CallBatch(ctx, CallModeRW, "echo", []CallBatchItem{
+			{BucketID: 1, Args: []interface{}{}},
+			{BucketID: 1, Args: []interface{}{}},
+		}, CallOpts{})
+		require.NoError(t, err)
+
+		require.NoError(t, results[0].Err)
+
+		var vshardError *StorageCallVShardError
+		require.ErrorAs(t, results[1].Err, &vshardError)
+		require.Equal(t, VShardErrNameStorageIsDisabled, vshardError.Name)
+	})
+
+	t.Run("retry connection error in RE mode", func(t *testing.T) {
+		t.Parallel()
+
+		connErr := tarantool.ClientError{Code: tarantool.ErrConnectionClosed, Msg: "connection closed"}
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).Return(newErrorFuture(connErr)).Once()
+		mPool.On("Do", mock.Anything, pool.RO).Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		results, err := router.CallBatch(ctx, CallModeRE, "echo", []CallBatchItem{
+			{BucketID: 1, Args: []interface{}{}},
+		}, CallOpts{})
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+	})
+
+	t.Run("connection error is not retried in RW mode", func(t *testing.T) {
+		t.Parallel()
+
+		connErr := tarantool.ClientError{Code: tarantool.ErrConnectionClosed, Msg: "connection closed"}
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).Return(newErrorFuture(connErr)).Once()
+
+		router := newTestRouter(mPool)
+
+		results, err := router.CallBatch(ctx, CallModeRW, "echo", []CallBatchItem{
+			{BucketID: 1, Args: []interface{}{}},
+		}, CallOpts{})
+		require.NoError(t, err)
+		require.ErrorIs(t, results[0].Err, connErr)
+	})
+
+	t.Run("retry policy", func(t *testing.T) {
+		t.Parallel()
+
+		wrongBucketErr := StorageCallVShardError{
+			BucketID: 1,
+			Name:     VShardErrNameWrongBucket,
+			Code:     VShardErrCodeWrongBucket,
+		}
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Once()
+
+		router := newTestRouter(mPool)
+		_, _ = router.BucketSet(1, "replicaset_1")
+
+		results, err := router.CallBatch(ctx, CallModeRW, "echo", []CallBatchItem{
+			{BucketID: 1, Args: []interface{}{}},
+		}, CallOpts{RetryPolicy: NoRetryPolicy{}})
+		require.NoError(t, err)
+
+		var vshardError *StorageCallVShardError
+		require.ErrorAs(t, results[0].Err, &vshardError)
+		require.Equal(t, VShardErrNameWrongBucket, vshardError.Name)
+	})
+
+	t.Run("circuit breaker", func(t *testing.T) {
+		t.Parallel()
+
+		connErr := tarantool.ClientError{Code: tarantool.ErrConnectionClosed, Msg: "connection closed"}
+
+		// the failures of a group are counted once
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).Return(func(_ tarantool.Request, _ pool.Mode) *tarantool.Future {
+			return newErrorFuture(connErr)
+		}).Times(4)
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.breaker = newCircuitBreaker(CircuitBreakerOpts{FailureThreshold: 2, OpenTimeout: time.Minute}, nil)
+
+		batch := []CallBatchItem{{BucketID: 1, Args: []interface{}{}}, {BucketID: 2, Args: []interface{}{}}}
+
+		for i := 0; i < 2; i++ {
+			results, err := router.CallBatch(ctx, CallModeRW, "echo", batch, CallOpts{})
+			require.NoError(t, err)
+			require.ErrorIs(t, results[0].Err, connErr)
+		}
+
+		// the open breaker rejects the items without sending them
+		results, err := router.CallBatch(ctx, CallModeRW, "echo", batch, CallOpts{})
+		require.NoError(t, err)
+
+		for _, result := range results {
+			var vshardError *StorageCallVShardError
+			require.ErrorAs(t, result.Err, &vshardError)
+			require.Equal(t, VShardErrNameReplicasetInBackoff, vshardError.Name)
+		}
+	})
+
+	t.Run("instance selection", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{
+			"master":  {ConnectedNow: true, ConnRole: pool.MasterRole},
+			"replica": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		})
+		// the items are sent to the instance chosen by the balancer
+		mPool.On("DoInstance", mock.Anything, "master").Return(func(_ tarantool.Request, _ string) *tarantool.Future {
+			return newCallResponseFuture(t, []interface{}{true, "ok"})
+		}).Twice()
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.balancer = newBalancer(BalancerOpts{}, nil)
+		rs.balancer.observe("replica", time.Second, false)
+
+		results, err := router.CallBatch(ctx, CallModeBRO, "echo", []CallBatchItem{
+			{BucketID: 1, Args: []interface{}{}},
+			{BucketID: 2, Args: []interface{}{}},
+		}, CallOpts{})
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		require.NoError(t, results[1].Err)
+
+		rs.balancer.mutex.Lock()
+		require.Contains(t, rs.balancer.stats, "master")
+		rs.balancer.mutex.Unlock()
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestRouter(mockpool.NewPooler(t))
+
+		_, err := router.CallBatch(ctx, CallMode(100), "echo", nil, CallOpts{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,106 @@
+package vshard_router //nolint:revive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// CallArityError is returned by CallTyped[XXX] functions
+// when the number of values returned by user defined function doesn't match the expected one.
+type CallArityError struct {
+	Fnc      string
+	Expected int
+	Actual   int
+}
+
+func (e CallArityError) Error() string {
+	return fmt.Sprintf("function %s has returned %d values, but %d expected", e.Fnc, e.Actual, e.Expected)
+}
+
+// decodeResults decodes values returned by user defined function into results one by one.
+func (r VshardRouterCallResp) decodeResults(fnc string, results ...interface{}) error {
+	d := msgpack.NewDecoder(bytes.NewReader(r.buf.Bytes()))
+
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	if n != len(results) {
+		return CallArityError{Fnc: fnc, Expected: len(results), Actual: n}
+	}
+
+	for i, result := range results {
+		if err := d.Decode(result); err != nil {
+			return fmt.Errorf("failed to decode value %d returned by function %s: %w", i+1, fnc, err)
+		}
+	}
+
+	return nil
+}
+
+// CallTyped calls Router.Call and decodes the only value returned by user defined function 'fnc' into T.
+// We define it as a distinct function, not a Router method, because golang limitations,
+// see: https://github.com/golang/go/issues/49085.
+func CallTyped[T any](r *Router, ctx context.Context, bucketID uint64, mode CallMode,
+	fnc string, args interface{}, opts CallOpts) (T, error) {
+	var result T
+
+	resp, err := r.Call(ctx, bucketID, mode, fnc, args, opts)
+	if err != nil {
+		return result, err
+	}
+
+	err = resp.decodeResults(fnc, &result)
+
+	return result, err
+}
+
+// CallTyped2 calls Router.Call and decodes two values returned by user defined function 'fnc' into T1 and T2.
+func CallTyped2[T1, T2 any](r *Router, ctx context.Context, bucketID uint64, mode CallMode,
+	fnc string, args interface{}, opts CallOpts) (T1, T2, error) {
+	var result1 T1
+	var result2 T2
+
+	resp, err := r.Call(ctx, bucketID, mode, fnc, args, opts)
+	if err != nil {
+		return result1, result2, err
+	}
+
+	err = resp.decodeResults(fnc, &result1, &result2)
+
+	return result1, result2, err
+}
+
+// CallTypedRO is an alias for CallTyped with CallModeRO.
+func CallTypedRO[T any](r *Router, ctx context.Context, bucketID uint64,
+	fnc string, args interface{}, opts CallOpts) (T, error) {
+	return CallTyped[T](r, ctx, bucketID, CallModeRO, fnc, args, opts)
+}
+
+// CallTypedRW is an alias for CallTyped with CallModeRW.
+func CallTypedRW[T any](r *Router, ctx context.Context, bucketID uint64,
+	fnc string, args interface{}, opts CallOpts) (T, error) {
+	return CallTyped[T](r, ctx, bucketID, CallModeRW, fnc, args, opts)
+}
+
+// CallTypedRE is an alias for CallTyped with CallModeRE.
+func CallTypedRE[T any](r *Router, ctx context.Context, bucketID uint64,
+	fnc string, args interface{}, opts CallOpts) (T, error) {
+	return CallTyped[T](r, ctx, bucketID, CallModeRE, fnc, args, opts)
+}
+
+// CallTypedBRO is an alias for CallTyped with CallModeBRO.
+func CallTypedBRO[T any](r *Router, ctx context.Context, bucketID uint64,
+	fnc string, args interface{}, opts CallOpts) (T, error) {
+	return CallTyped[T](r, ctx, bucketID, CallModeBRO, fnc, args, opts)
+}
+
+// CallTypedBRE is an alias for CallTyped with CallModeBRE.
+func CallTypedBRE[T any](r *Router, ctx context.Context, bucketID uint64,
+	fnc string, args interface{}, opts CallOpts) (T, error) {
+	return CallTyped[T](r, ctx, bucketID, CallModeBRE, fnc, args, opts)
+}
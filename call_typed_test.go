@@ -0,0 +1,78 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+func TestCallTyped(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	type user struct {
+		ID   uint64 `msgpack:"id"`
+		Name string `msgpack:"name"`
+	}
+
+	t.Run("one value", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, user{ID: 1, Name: "name"}})).Once()
+
+		router := newTestRouter(mPool)
+
+		result, err := CallTypedRW[user](router, ctx, 1, "get_user", []interface{}{1}, CallOpts{})
+		require.NoError(t, err)
+		require.Equal(t, user{ID: 1, Name: "name"}, result)
+	})
+
+	t.Run("two values", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).
+			Return(newCallResponseFuture(t, []interface{}{true, nil, "not found"})).Once()
+
+		router := newTestRouter(mPool)
+
+		result, errMsg, err := CallTyped2[*user, string](router, ctx, 1, CallModeRO, "get_user", []interface{}{1}, CallOpts{})
+		require.NoError(t, err)
+		require.Nil(t, result)
+		require.Equal(t, "not found", errMsg)
+	})
+
+	t.Run("arity mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).
+			Return(newCallResponseFuture(t, []interface{}{true, 1, 2})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := CallTypedRO[int](router, ctx, 1, "get_user", []interface{}{1}, CallOpts{})
+		require.ErrorIs(t, err, CallArityError{Fnc: "get_user", Expected: 1, Actual: 2})
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).
+			Return(newCallResponseFuture(t, []interface{}{true, "str"})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := CallTypedRO[int](router, ctx, 1, "get_user", []interface{}{1}, CallOpts{})
+		require.Error(t, err)
+	})
+}
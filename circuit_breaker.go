@@ -0,0 +1,208 @@
+package vshard_router //nolint:revive
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	circuitBreakerFailureThresholdDefault = 5
+	// circuitBreakerOpenTimeoutDefault is the same as REPLICASET_BACKOFF_INTERVAL of lua vshard router.
+	circuitBreakerOpenTimeoutDefault    = 5 * time.Second
+	circuitBreakerHalfOpenProbesDefault = 1
+)
+
+// CircuitBreakerOpts enables a circuit breaker for each replicaset.
+// The breaker trips (opens) after FailureThreshold consecutive connection or timeout errors, and
+// Router.Call fails fast with REPLICASET_IN_BACKOFF vshard error for buckets of the replicaset while it is open,
+// as lua vshard router does. After OpenTimeout the breaker becomes half-open and lets HalfOpenProbes
+// requests through: it is closed if they succeed and opened again otherwise.
+type CircuitBreakerOpts struct {
+	// FailureThreshold is a number of consecutive failed requests to trip the breaker. Default is 5.
+	FailureThreshold int
+	// OpenTimeout is a time the breaker stays open before letting probes through. Default is 5s.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is a number of concurrent probe requests in half-open state. Default is 1.
+	HalfOpenProbes int
+}
+
+// CircuitBreakerState is a state of replicaset circuit breaker.
+type CircuitBreakerState int
+
+const (
+	// CircuitBreakerClosed means that requests are sent to the replicaset as usual.
+	CircuitBreakerClosed CircuitBreakerState = iota
+	// CircuitBreakerOpen means that requests to the replicaset fail fast.
+	CircuitBreakerOpen
+	// CircuitBreakerHalfOpen means that only probe requests are sent to the replicaset.
+	CircuitBreakerHalfOpen
+)
+
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerClosed:
+		return "closed"
+	case CircuitBreakerOpen:
+		return "open"
+	case CircuitBreakerHalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker is a circuit breaker of a single replicaset, nil breaker always allows requests.
+type circuitBreaker struct {
+	opts          CircuitBreakerOpts
+	onStateChange func(state CircuitBreakerState)
+
+	mutex    sync.Mutex
+	state    CircuitBreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+	lastErr  error
+}
+
+func newCircuitBreaker(opts CircuitBreakerOpts, onStateChange func(state CircuitBreakerState)) *circuitBreaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = circuitBreakerFailureThresholdDefault
+	}
+
+	if opts.OpenTimeout <= 0 {
+		opts.OpenTimeout = circuitBreakerOpenTimeoutDefault
+	}
+
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = circuitBreakerHalfOpenProbesDefault
+	}
+
+	return &circuitBreaker{
+		opts:          opts,
+		onStateChange: onStateChange,
+	}
+}
+
+// setState must be called with mutex locked, it reports whether the state has been changed.
+// onStateChange is not called here: the caller must notify about the new state after unlocking the mutex,
+// so metrics and logging code never runs under the breaker lock.
+func (cb *circuitBreaker) setState(state CircuitBreakerState) bool {
+	if cb.state == state {
+		return false
+	}
+
+	cb.state = state
+
+	switch state {
+	case CircuitBreakerOpen:
+		cb.openedAt = time.Now()
+	case CircuitBreakerHalfOpen:
+		cb.probes = 0
+	case CircuitBreakerClosed:
+		cb.failures = 0
+	}
+
+	return true
+}
+
+func (cb *circuitBreaker) notify(state CircuitBreakerState, changed bool) {
+	if changed && cb.onStateChange != nil {
+		cb.onStateChange(state)
+	}
+}
+
+// allow reports whether a request may be sent to the replicaset and whether it is a probe request.
+// If the request is not allowed, the last error that has tripped the breaker is returned.
+func (cb *circuitBreaker) allow() (probe bool, allowed bool, lastErr error) {
+	if cb == nil {
+		return false, true, nil
+	}
+
+	cb.mutex.Lock()
+
+	var changed bool
+	if cb.state == CircuitBreakerOpen && time.Since(cb.openedAt) >= cb.opts.OpenTimeout {
+		changed = cb.setState(CircuitBreakerHalfOpen)
+	}
+
+	state := cb.state
+
+	switch {
+	case state == CircuitBreakerClosed:
+		allowed = true
+	case state == CircuitBreakerHalfOpen && cb.probes < cb.opts.HalfOpenProbes:
+		cb.probes++
+		probe, allowed = true, true
+	default:
+		lastErr = cb.lastErr
+	}
+
+	cb.mutex.Unlock()
+
+	cb.notify(state, changed)
+
+	return probe, allowed, lastErr
+}
+
+// done records the result of the request allowed by allow. err is not nil if the request has failed
+// due to connection error or timeout.
+func (cb *circuitBreaker) done(probe bool, err error) {
+	if cb == nil {
+		return
+	}
+
+	cb.mutex.Lock()
+	state, changed := cb.doneLocked(probe, err)
+	cb.mutex.Unlock()
+
+	cb.notify(state, changed)
+}
+
+func (cb *circuitBreaker) doneLocked(probe bool, err error) (CircuitBreakerState, bool) {
+	if probe {
+		if cb.state != CircuitBreakerHalfOpen {
+			return cb.state, false
+		}
+
+		cb.probes--
+
+		if err != nil {
+			cb.lastErr = err
+			return CircuitBreakerOpen, cb.setState(CircuitBreakerOpen)
+		}
+
+		return CircuitBreakerClosed, cb.setState(CircuitBreakerClosed)
+	}
+
+	if cb.state != CircuitBreakerClosed {
+		// the request has been sent before the breaker has tripped
+		return cb.state, false
+	}
+
+	if err == nil {
+		cb.failures = 0
+
+		return cb.state, false
+	}
+
+	cb.failures++
+	if cb.failures >= cb.opts.FailureThreshold {
+		cb.lastErr = err
+		return CircuitBreakerOpen, cb.setState(CircuitBreakerOpen)
+	}
+
+	return cb.state, false
+}
+
+// CircuitBreakerState returns the state of the replicaset circuit breaker.
+// It is always CircuitBreakerClosed if Config.CircuitBreaker is not set.
+func (rs *Replicaset) CircuitBreakerState() CircuitBreakerState {
+	if rs.breaker == nil {
+		return CircuitBreakerClosed
+	}
+
+	rs.breaker.mutex.Lock()
+	defer rs.breaker.mutex.Unlock()
+
+	return rs.breaker.state
+}
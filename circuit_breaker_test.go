@@ -0,0 +1,151 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var states []CircuitBreakerState
+
+	cb := newCircuitBreaker(CircuitBreakerOpts{
+		FailureThreshold: 2,
+		OpenTimeout:      50 * time.Millisecond,
+	}, func(state CircuitBreakerState) {
+		states = append(states, state)
+	})
+
+	errFailure := errors.New("failure")
+
+	// success resets the counter of consecutive failures
+	cb.done(false, errFailure)
+	cb.done(false, nil)
+	cb.done(false, errFailure)
+
+	_, allowed, _ := cb.allow()
+	require.True(t, allowed)
+
+	// trip
+	cb.done(false, errFailure)
+
+	_, allowed, lastErr := cb.allow()
+	require.False(t, allowed)
+	require.ErrorIs(t, lastErr, errFailure)
+
+	time.Sleep(50 * time.Millisecond)
+
+	// half-open: only one probe is allowed
+	probe, allowed, _ := cb.allow()
+	require.True(t, probe)
+	require.True(t, allowed)
+
+	_, allowed, _ = cb.allow()
+	require.False(t, allowed)
+
+	// failed probe opens the breaker again
+	cb.done(true, errFailure)
+
+	_, allowed, _ = cb.allow()
+	require.False(t, allowed)
+
+	time.Sleep(50 * time.Millisecond)
+
+	// successful probe closes the breaker
+	probe, allowed, _ = cb.allow()
+	require.True(t, probe)
+	require.True(t, allowed)
+
+	cb.done(true, nil)
+
+	probe, allowed, _ = cb.allow()
+	require.False(t, probe)
+	require.True(t, allowed)
+
+	require.Equal(t, []CircuitBreakerState{
+		CircuitBreakerOpen,
+		CircuitBreakerHalfOpen,
+		CircuitBreakerOpen,
+		CircuitBreakerHalfOpen,
+		CircuitBreakerClosed,
+	}, states)
+
+	// nil breaker always allows requests
+	var nilBreaker *circuitBreaker
+
+	_, allowed, _ = nilBreaker.allow()
+	require.True(t, allowed)
+}
+
+func TestRouter_Call_CircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mPool := mockpool.NewPooler(t)
+	mPool.On("Do", mock.Anything, pool.RW).
+		Return(newErrorFuture(tarantool.ClientError{Code: tarantool.ErrConnectionClosed})).Twice()
+
+	router := newTestRouter(mPool)
+
+	rs := router.getNameToReplicaset()["replicaset_1"]
+
+	var observed []CircuitBreakerState
+
+	// state change callback is called without the breaker lock held
+	rs.breaker = newCircuitBreaker(CircuitBreakerOpts{FailureThreshold: 2, OpenTimeout: time.Minute},
+		func(_ CircuitBreakerState) {
+			observed = append(observed, rs.CircuitBreakerState())
+		})
+
+	for i := 0; i < 2; i++ {
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.Error(t, err)
+	}
+
+	require.Equal(t, CircuitBreakerOpen, rs.CircuitBreakerState())
+	require.Equal(t, []CircuitBreakerState{CircuitBreakerOpen}, observed)
+
+	// the request is not sent to the replicaset
+	_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+
+	var vshardError *StorageCallVShardError
+	require.ErrorAs(t, err, &vshardError)
+	require.Equal(t, VShardErrNameReplicasetInBackoff, vshardError.Name)
+}
+
+func TestRouter_Call_CircuitBreaker_NoReplicas(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mPool := mockpool.NewPooler(t)
+	mPool.On("Do", mock.Anything, pool.RO).Return(newErrorFuture(pool.ErrNoRoInstance)).Times(5)
+	mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+	router := newTestRouter(mPool)
+
+	rs := router.getNameToReplicaset()["replicaset_1"]
+	rs.breaker = newCircuitBreaker(CircuitBreakerOpts{FailureThreshold: 2, OpenTimeout: time.Minute}, nil)
+
+	// an outage of replicas doesn't mean the master is unavailable
+	for i := 0; i < 5; i++ {
+		_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.ErrorIs(t, err, pool.ErrNoRoInstance)
+	}
+
+	require.Equal(t, CircuitBreakerClosed, rs.CircuitBreakerState())
+
+	_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.NoError(t, err)
+}
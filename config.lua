@@ -141,6 +141,8 @@ else
         box.schema.role.grant('public', 'execute', 'function', 'raise_luajit_error')
         box.schema.func.create('raise_client_error')
         box.schema.role.grant('public', 'execute', 'function', 'raise_client_error')
+        box.schema.func.create('push_echo')
+        box.schema.role.grant('public', 'execute', 'function', 'push_echo')
 
         box.schema.user.grant('storage', 'super')
         box.schema.user.create('tarantool')
@@ -196,6 +198,13 @@ else
         return ...
     end
 
+    function push_echo(...)
+        for _, value in ipairs({...}) do
+            box.session.push(value)
+        end
+        return select('#', ...)
+    end
+
     function sleep(time)
         fiber.sleep(time)
         return true
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/errgroup"
@@ -21,9 +22,104 @@ type DiscoveryMode int
 const (
 	// DiscoveryModeOn is cron discovery with cron timeout
 	DiscoveryModeOn DiscoveryMode = iota
+	// DiscoveryModeOnce discovers all buckets only once, when the router is created.
 	DiscoveryModeOnce
+	// DiscoveryModeOff disables discovery of all buckets, buckets are discovered only on demand by Router.Route,
+	// as 'off' discovery mode of lua router does.
+	DiscoveryModeOff
+	// DiscoveryModeAdaptive is cron discovery that runs often while the route map has unknown buckets
+	// or after a storm of WRONG_BUCKET errors, and backs off up to 8 DiscoveryTimeout-s
+	// while the route map is complete and stable, as lua router slows down discovery once all buckets are known.
+	DiscoveryModeAdaptive
 )
 
+const (
+	// discoveryAdaptiveFastTimeout is the timeout of DiscoveryModeAdaptive while buckets are unknown.
+	discoveryAdaptiveFastTimeout = time.Second
+	// discoveryAdaptiveMaxFactor limits the back off of DiscoveryModeAdaptive as DiscoveryTimeout multiplier.
+	discoveryAdaptiveMaxFactor = 8
+	// discoveryAdaptiveWrongBucketStorm is the number of WRONG_BUCKET errors since the last discovery
+	// that triggers the next one immediately.
+	discoveryAdaptiveWrongBucketStorm = 10
+)
+
+// adaptiveDiscovery computes the timeout of DiscoveryModeAdaptive cron discovery.
+type adaptiveDiscovery struct {
+	baseTimeout time.Duration
+	fastTimeout time.Duration
+	maxTimeout  time.Duration
+
+	// timeout is the current timeout, it's used only by the cron discovery goroutine
+	timeout time.Duration
+
+	// wrongBuckets counts WRONG_BUCKET errors since the last discovery
+	wrongBuckets atomic.Int64
+	// storm is signaled when wrongBuckets reaches discoveryAdaptiveWrongBucketStorm
+	storm chan struct{}
+}
+
+func newAdaptiveDiscovery(baseTimeout time.Duration) *adaptiveDiscovery {
+	return &adaptiveDiscovery{
+		baseTimeout: baseTimeout,
+		fastTimeout: min(baseTimeout, discoveryAdaptiveFastTimeout),
+		maxTimeout:  baseTimeout * discoveryAdaptiveMaxFactor,
+		timeout:     baseTimeout,
+		storm:       make(chan struct{}, 1),
+	}
+}
+
+// wrongBucket is called on WRONG_BUCKET error (and other bucket moving errors) of a request.
+func (d *adaptiveDiscovery) wrongBucket() {
+	if d == nil {
+		return
+	}
+
+	if d.wrongBuckets.Add(1) == discoveryAdaptiveWrongBucketStorm {
+		select {
+		case d.storm <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// update computes the timeout before the next discovery by the result of the last one.
+func (d *adaptiveDiscovery) update(unknownBuckets uint64, failed bool) time.Duration {
+	// the storm signaled during the last discovery is handled by it, so it must not trigger one more
+	select {
+	case <-d.storm:
+	default:
+	}
+
+	wrongBuckets := d.wrongBuckets.Swap(0)
+
+	switch {
+	case unknownBuckets > 0 || wrongBuckets >= discoveryAdaptiveWrongBucketStorm:
+		d.timeout = d.fastTimeout
+	case failed || wrongBuckets > 0 || d.timeout < d.baseTimeout:
+		d.timeout = d.baseTimeout
+	default:
+		// the route map is complete and stable
+		d.timeout = min(d.timeout*2, d.maxTimeout)
+	}
+
+	return d.timeout
+}
+
+// unknownBucketCount returns the number of buckets missing in the route map.
+func (r *Router) unknownBucketCount() uint64 {
+	routeMap := r.getRouteMap()
+
+	var unknown uint64
+
+	for bucketID := uint64(1); bucketID <= r.cfg.TotalBucketCount; bucketID++ {
+		if routeMap[bucketID].Load() == nil {
+			unknown++
+		}
+	}
+
+	return unknown
+}
+
 // BucketsSearchMode a type, that used to define policy for Router.Route method.
 // See type Config for further details.
 type BucketsSearchMode int
@@ -251,6 +347,12 @@ func (r *Router) DiscoveryAllBuckets(ctx context.Context) error {
 	routeMap := r.getRouteMap()
 	nameToReplicasetRef := r.getNameToReplicaset()
 
+	workStep := r.cfg.DiscoveryWorkStep
+	if r.adaptiveDiscovery != nil && r.unknownBucketCount() > 0 {
+		// Adaptive discovery fetches unknown buckets as fast as possible, as lua router does in aggressive mode.
+		workStep = 0
+	}
+
 	for _, rs := range nameToReplicasetRef {
 		rs := rs
 
@@ -286,7 +388,9 @@ func (r *Router) DiscoveryAllBuckets(ctx context.Context) error {
 
 				// Don't spam many requests at once. Give storages time to handle them and other requests.
 				// https://github.com/tarantool/vshard/blob/b6fdbe950a2e4557f05b83bd8b846b126ec3724e/vshard/router/init.lua#L308
-				time.Sleep(r.cfg.DiscoveryWorkStep)
+				if workStep > 0 {
+					time.Sleep(workStep)
+				}
 			}
 		})
 	}
@@ -304,14 +408,25 @@ func (r *Router) DiscoveryAllBuckets(ctx context.Context) error {
 func (r *Router) cronDiscovery(ctx context.Context) {
 	var iterationCount uint64
 
+	timeout := r.cfg.DiscoveryTimeout
+
+	var storm <-chan struct{}
+	if r.adaptiveDiscovery != nil {
+		timeout = r.adaptiveDiscovery.update(r.unknownBucketCount(), false)
+		storm = r.adaptiveDiscovery.storm
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			r.metrics().CronDiscoveryEvent(false, 0, "ctx-cancel")
 			r.log().Infof(ctx, "[DISCOVERY] cron discovery has been stopped after %d iterations", iterationCount)
 			return
-		case <-time.After(r.cfg.DiscoveryTimeout):
+		case <-time.After(timeout):
+			iterationCount++
+		case <-storm:
 			iterationCount++
+			r.log().Infof(ctx, "[DISCOVERY] WRONG_BUCKET errors storm, start discovery earlier")
 		}
 
 		// Since the current for loop should not stop until ctx->Done() event fires,
@@ -340,7 +455,18 @@ func (r *Router) cronDiscovery(ctx context.Context) {
 
 			tStartDiscovery := time.Now()
 
-			if err := r.DiscoveryAllBuckets(ctx); err != nil {
+			err := r.DiscoveryAllBuckets(ctx)
+
+			if r.adaptiveDiscovery != nil {
+				prevTimeout := timeout
+				timeout = r.adaptiveDiscovery.update(r.unknownBucketCount(), err != nil)
+
+				if timeout != prevTimeout {
+					r.log().Infof(ctx, "[DISCOVERY] cron discovery timeout has changed from %s to %s", prevTimeout, timeout)
+				}
+			}
+
+			if err != nil {
 				r.metrics().CronDiscoveryEvent(false, time.Since(tStartDiscovery), "discovery-error")
 				r.log().Errorf(ctx, "[DISCOVERY] cant do cron discovery iteration %d with error: %s", iterationCount, err)
 				return
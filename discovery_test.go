@@ -0,0 +1,115 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+func TestAdaptiveDiscovery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("timeouts", func(t *testing.T) {
+		t.Parallel()
+
+		d := newAdaptiveDiscovery(10 * time.Second)
+
+		// unknown buckets speed up discovery
+		require.Equal(t, discoveryAdaptiveFastTimeout, d.update(5, false))
+
+		// the route map is complete
+		require.Equal(t, 10*time.Second, d.update(0, false))
+
+		// the route map is stable
+		require.Equal(t, 20*time.Second, d.update(0, false))
+		require.Equal(t, 40*time.Second, d.update(0, false))
+		require.Equal(t, 80*time.Second, d.update(0, false))
+		require.Equal(t, 80*time.Second, d.update(0, false))
+
+		// a few WRONG_BUCKET errors reset the back off
+		d.wrongBucket()
+		require.Equal(t, 10*time.Second, d.update(0, false))
+		require.Equal(t, 20*time.Second, d.update(0, false))
+
+		// a failed discovery resets the back off too
+		require.Equal(t, 10*time.Second, d.update(0, true))
+	})
+
+	t.Run("fast timeout is not greater than base one", func(t *testing.T) {
+		t.Parallel()
+
+		d := newAdaptiveDiscovery(100 * time.Millisecond)
+		require.Equal(t, 100*time.Millisecond, d.update(1, false))
+	})
+
+	t.Run("WRONG_BUCKET storm", func(t *testing.T) {
+		t.Parallel()
+
+		d := newAdaptiveDiscovery(time.Minute)
+
+		for i := 0; i < discoveryAdaptiveWrongBucketStorm-1; i++ {
+			d.wrongBucket()
+		}
+
+		require.Empty(t, d.storm)
+
+		d.wrongBucket()
+		d.wrongBucket()
+		require.Len(t, d.storm, 1)
+
+		require.Equal(t, discoveryAdaptiveFastTimeout, d.update(0, false))
+		// the storm is handled by the discovery that has just finished
+		require.Empty(t, d.storm)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		t.Parallel()
+
+		var d *adaptiveDiscovery
+		require.NotPanics(t, d.wrongBucket)
+	})
+}
+
+func TestRouter_unknownBucketCount(t *testing.T) {
+	t.Parallel()
+
+	router := newTestMapRouter(10, map[string]Pooler{"rs": mockpool.NewPooler(t)})
+	require.Equal(t, uint64(10), router.unknownBucketCount())
+
+	_, err := router.BucketSet(1, "rs")
+	require.NoError(t, err)
+	_, err = router.BucketSet(10, "rs")
+	require.NoError(t, err)
+
+	require.Equal(t, uint64(8), router.unknownBucketCount())
+}
+
+func TestRouter_Call_AdaptiveDiscovery(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	wrongBucketErr := StorageCallVShardError{
+		BucketID:    1,
+		Name:        VShardErrNameWrongBucket,
+		Code:        VShardErrCodeWrongBucket,
+		Destination: "replicaset_1",
+	}
+
+	mPool := mockpool.NewPooler(t)
+	mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Once()
+	mPool.On("Do", mock.Anything, pool.RW).Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+	router := newTestRouter(mPool)
+	router.adaptiveDiscovery = newAdaptiveDiscovery(time.Minute)
+
+	_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), router.adaptiveDiscovery.wrongBuckets.Load())
+}
@@ -1,6 +1,12 @@
 package vshard_router //nolint:revive
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
 
 // VShard error codes
 const (
@@ -101,3 +107,44 @@ func newVShardErrorNoRouteToBucket(bucketID uint64) error {
 		Message:  fmt.Sprintf("Bucket %d cannot be found. Is rebalancing in progress?", bucketID),
 	}
 }
+
+func newVShardErrorReplicasetInBackoff(rsInfo ReplicasetInfo, lastErr error) error {
+	return &StorageCallVShardError{
+		Name:           VShardErrNameReplicasetInBackoff,
+		Code:           VShardErrCodeReplicasetInBackoff,
+		Type:           "ShardingError",
+		ReplicasetUUID: rsInfo.UUID.String(),
+		Reason:         fmt.Sprint(lastErr),
+		Message: fmt.Sprintf("Replicaset %s is in backoff, can't take requests right now. Last error was %v",
+			rsInfo.Name, lastErr),
+	}
+}
+
+// isConnectionError reports whether err has been caused by connection failure,
+// so the request may succeed on another instance of the same replicaset.
+func isConnectionError(err error) bool {
+	if errors.Is(err, pool.ErrNoRoInstance) || errors.Is(err, pool.ErrNoRwInstance) ||
+		errors.Is(err, pool.ErrNoHealthyInstance) {
+		return true
+	}
+
+	return isTransportError(err)
+}
+
+// isTransportError reports whether err has been caused by a failure of the connection the request has been sent to.
+// Unlike isConnectionError, it doesn't match the pool errors about missing instances of some role:
+// e.g. there may be no healthy replicas while the master works fine.
+func isTransportError(err error) bool {
+	var clientErr tarantool.ClientError
+	if !errors.As(err, &clientErr) {
+		return false
+	}
+
+	switch clientErr.Code {
+	case tarantool.ErrConnectionNotReady, tarantool.ErrConnectionClosed, tarantool.ErrConnectionShutdown,
+		tarantool.ErrTimeouted, tarantool.ErrIoError:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,240 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+const (
+	hedgeDelayDefault       = 50 * time.Millisecond
+	hedgeMaxRequestsDefault = 2
+
+	// latencyWindowSize is a number of recent response times kept per replicaset.
+	latencyWindowSize = 256
+	// latencyMinSamples is a minimum number of response times to calculate a percentile.
+	latencyMinSamples = 16
+)
+
+// HedgeOpts enables hedged requests for CallModeRO and CallModeBRO calls.
+// If an instance doesn't respond within the delay, the same request is sent to another instance
+// of the same replicaset. The first response wins, the rest requests are cancelled.
+// Note that a vshard error (e.g. WRONG_BUCKET) or any other error except connection errors is a response too,
+// it wins and is returned. Only connection errors are skipped.
+type HedgeOpts struct {
+	// Delay is a pause before sending the request to the next instance. Default is 50ms.
+	Delay time.Duration
+	// Percentile (0, 100) of recent response times of the replicaset is used as the delay, if it is set.
+	// Delay is used until enough response times are collected. The response times of all calls with HedgeOpts
+	// are collected, whether they have been hedged or not.
+	Percentile float64
+	// MaxRequests limits the number of requests sent for the call, including the first one. Default is 2.
+	MaxRequests int
+}
+
+// latencyTracker keeps recent response times of replicaset for HedgeOpts.Percentile.
+type latencyTracker struct {
+	mutex   sync.Mutex
+	samples [latencyWindowSize]time.Duration
+	count   int
+	next    int
+}
+
+func (lt *latencyTracker) add(latency time.Duration) {
+	if lt == nil {
+		return
+	}
+
+	lt.mutex.Lock()
+	defer lt.mutex.Unlock()
+
+	lt.samples[lt.next] = latency
+	lt.next = (lt.next + 1) % latencyWindowSize
+
+	if lt.count < latencyWindowSize {
+		lt.count++
+	}
+}
+
+// percentile returns the percentile of recent response times or false if there are not enough of them.
+func (lt *latencyTracker) percentile(percentile float64) (time.Duration, bool) {
+	if lt == nil {
+		return 0, false
+	}
+
+	lt.mutex.Lock()
+	samples := slices.Clone(lt.samples[:lt.count])
+	lt.mutex.Unlock()
+
+	if len(samples) < latencyMinSamples {
+		return 0, false
+	}
+
+	slices.Sort(samples)
+
+	idx := int(math.Ceil(percentile/100*float64(len(samples)))) - 1
+	idx = max(0, min(idx, len(samples)-1))
+
+	return samples[idx], true
+}
+
+// hedgeDelay returns a pause before sending the request to the next instance.
+func (rs *Replicaset) hedgeDelay(opts HedgeOpts) time.Duration {
+	if opts.Percentile > 0 {
+		if delay, ok := rs.latencies.percentile(opts.Percentile); ok {
+			return delay
+		}
+	}
+
+	if opts.Delay > 0 {
+		return opts.Delay
+	}
+
+	return hedgeDelayDefault
+}
+
+type hedgeResult struct {
+	resp    vshardStorageCallResponseProto
+	err     error
+	latency time.Duration
+	// n is the sequence number of the request, the first one is 0.
+	n int
+}
+
+// hedgedCall sends vshard.storage.call with args to instances of the replicaset one by one
+// with the delay chosen by opts, until the first response is received.
+func (r *Router) hedgedCall(ctx context.Context, rs *Replicaset, mode pool.Mode, opts HedgeOpts,
+	args []interface{}, resp *vshardStorageCallResponseProto) error {
+	instances := rs.orderedInstances(mode)
+
+	maxRequests := opts.MaxRequests
+	if maxRequests <= 0 {
+		maxRequests = hedgeMaxRequestsDefault
+	}
+
+	// the failed instance chosen by the router may be replaced by another one within MaxRequests
+	canResend := maxRequests > 1
+
+	maxRequests = min(maxRequests, len(instances))
+	if maxRequests < 2 || !rs.canDoInstance() {
+		// Nothing to hedge with, send a single request as Router.Call does.
+		instance, err := rs.readTarget(mode)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+
+		err = rs.CallAsync(ctx, ReplicasetCallOpts{PoolMode: mode, Instance: instance}, vshardStorageClientCall, args).
+			GetTyped(resp)
+		if err != nil && instance != "" && canResend && isConnectionError(err) {
+			// the instance chosen by the router has failed, let the pool choose another one
+			start = time.Now()
+			err = rs.CallAsync(ctx, ReplicasetCallOpts{PoolMode: mode}, vshardStorageClientCall, args).GetTyped(resp)
+		}
+
+		if err == nil || !isConnectionError(err) {
+			rs.latencies.add(time.Since(start))
+		}
+
+		return err
+	}
+
+	// cancel the requests that have lost
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, maxRequests)
+	delay := rs.hedgeDelay(opts)
+
+	var (
+		timer      *time.Timer
+		timerC     <-chan time.Time
+		sent       int
+		firstStart time.Time
+	)
+
+	sendNext := func() {
+		instance, n := instances[sent], sent
+		sent++
+
+		if n > 0 {
+			r.metrics().RetryOnCall("hedged_request")
+			r.log().Debugf(ctx, "Send hedged request to instance %s of replicaset %s", instance, rs.info.Name)
+		}
+
+		start := time.Now()
+		if n == 0 {
+			firstStart = start
+		}
+
+		future := rs.CallAsync(ctx, ReplicasetCallOpts{Instance: instance}, vshardStorageClientCall, args)
+
+		go func() {
+			result := hedgeResult{n: n}
+			result.err = future.GetTyped(&result.resp)
+			result.latency = time.Since(start)
+
+			results <- result
+		}()
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		timer, timerC = nil, nil
+		if sent < maxRequests {
+			timer = time.NewTimer(delay)
+			timerC = timer.C
+		}
+	}
+
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	sendNext()
+
+	var failed int
+
+	for {
+		select {
+		case <-timerC:
+			sendNext()
+		case result := <-results:
+			if result.err == nil || !isConnectionError(result.err) {
+				rs.latencies.add(result.latency)
+
+				if result.n > 0 {
+					// The first request has not completed yet, its response time is at least the elapsed time.
+					// Record it too, otherwise only the fastest of hedged responses are recorded,
+					// and the percentile shrinks over time.
+					rs.latencies.add(time.Since(firstStart))
+				}
+
+				*resp = result.resp
+
+				return result.err
+			}
+
+			failed++
+
+			if failed < sent {
+				continue
+			}
+
+			if sent == maxRequests {
+				return result.err
+			}
+
+			// All sent requests have failed, don't wait for the delay.
+			sendNext()
+		}
+	}
+}
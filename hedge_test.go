@@ -0,0 +1,174 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+func TestLatencyTracker_Percentile(t *testing.T) {
+	t.Parallel()
+
+	lt := &latencyTracker{}
+
+	_, ok := lt.percentile(90)
+	require.False(t, ok)
+
+	for i := 1; i <= 100; i++ {
+		lt.add(time.Duration(i) * time.Millisecond)
+	}
+
+	p90, ok := lt.percentile(90)
+	require.True(t, ok)
+	require.Equal(t, 90*time.Millisecond, p90)
+
+	p100, ok := lt.percentile(100)
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond, p100)
+}
+
+func TestRouter_Call_Hedge(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	replicas := map[string]pool.ConnectionInfo{
+		"instance_1": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		"instance_2": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		"instance_3": {ConnectedNow: false, ConnRole: pool.ReplicaRole},
+		"instance_4": {ConnectedNow: true, ConnRole: pool.MasterRole},
+	}
+
+	t.Run("slow instance", func(t *testing.T) {
+		t.Parallel()
+
+		slowFuture := tarantool.NewFuture(tarantool.NewCallRequest("vshard.storage.call"))
+		defer slowFuture.SetError(context.Canceled)
+
+		var requests atomic.Int32
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(replicas)
+		mPool.On("DoInstance", mock.Anything, mock.MatchedBy(func(name string) bool {
+			return name == "instance_1" || name == "instance_2"
+		})).Return(func(_ tarantool.Request, _ string) *tarantool.Future {
+			if requests.Add(1) == 1 {
+				return slowFuture
+			}
+
+			return newCallResponseFuture(t, []interface{}{true, "ok"})
+		}).Twice()
+
+		router := newTestRouter(mPool)
+
+		resp, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{
+			Hedge: &HedgeOpts{Delay: 10 * time.Millisecond},
+		})
+		require.NoError(t, err)
+
+		result, err := resp.Get()
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"ok"}, result)
+	})
+
+	t.Run("failed instance", func(t *testing.T) {
+		t.Parallel()
+
+		var requests atomic.Int32
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(replicas)
+		mPool.On("DoInstance", mock.Anything, mock.Anything).
+			Return(func(_ tarantool.Request, _ string) *tarantool.Future {
+				if requests.Add(1) == 1 {
+					return newErrorFuture(tarantool.ClientError{Code: tarantool.ErrConnectionClosed})
+				}
+
+				return newCallResponseFuture(t, []interface{}{true, "ok"})
+			}).Twice()
+
+		router := newTestRouter(mPool)
+
+		start := time.Now()
+
+		_, err := router.CallBRO(ctx, 1, "echo", []interface{}{}, CallOpts{
+			Hedge: &HedgeOpts{Delay: time.Minute},
+		})
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), time.Minute)
+	})
+
+	t.Run("balancer", func(t *testing.T) {
+		t.Parallel()
+
+		connErr := tarantool.ClientError{Code: tarantool.ErrConnectionClosed}
+
+		// both hedged requests fail, the call is not resent beyond MaxRequests
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(replicas)
+		mPool.On("DoInstance", mock.Anything, mock.Anything).
+			Return(func(_ tarantool.Request, _ string) *tarantool.Future {
+				return newErrorFuture(connErr)
+			}).Twice()
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.balancer = newBalancer(BalancerOpts{}, nil)
+
+		_, err := router.CallBRO(ctx, 1, "echo", []interface{}{}, CallOpts{
+			Hedge:       &HedgeOpts{Delay: time.Millisecond, MaxRequests: 2},
+			RetryPolicy: NoRetryPolicy{},
+		})
+		require.ErrorIs(t, err, connErr)
+
+		// the balancer doesn't observe instances it hasn't chosen
+		rs.balancer.mutex.Lock()
+		require.Empty(t, rs.balancer.stats)
+		rs.balancer.mutex.Unlock()
+	})
+
+	t.Run("single instance", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{
+			"instance_1": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		})
+		mPool.On("Do", mock.Anything, pool.RO).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{
+			Hedge: &HedgeOpts{},
+		})
+		require.NoError(t, err)
+
+		// the response time of not hedged request is collected too
+		require.Equal(t, 1, router.getNameToReplicaset()["replicaset_1"].latencies.count)
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(replicas)
+		// the error is a response, so the hedged request is not sent
+		mPool.On("DoInstance", mock.Anything, mock.Anything).
+			Return(newErrorFuture(tarantool.Error{Code: 32, Msg: "lua error"})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{
+			Hedge: &HedgeOpts{Delay: time.Minute},
+		})
+		require.ErrorContains(t, err, "lua error")
+	})
+}
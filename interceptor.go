@@ -0,0 +1,88 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"fmt"
+)
+
+// CallInfo describes a call passed to CallInterceptor.
+type CallInfo struct {
+	// BucketID is a bucket identifier of the call. It is 0 for map-reduce calls.
+	BucketID uint64
+	// Mode is a mode of the call. It is CallModeRW for map-reduce calls, except RouterMapCallRO.
+	Mode CallMode
+	// MapReduce is true for RouterMapCallRW and the other map-reduce functions.
+	MapReduce bool
+	// Fnc is a name of user defined function.
+	Fnc string
+	// Args are arguments of user defined function.
+	Args interface{}
+	// Attempts are appended by the router while the call is performed: one for every attempt of Router.Call,
+	// or one for every replicaset of map-reduce call. Interceptor must read them only after invoker has returned,
+	// use OnAttempt to observe attempts while the call is in progress.
+	Attempts []CallAttemptInfo
+	// OnAttempt is called by the router in the goroutine of the call as soon as an attempt has completed,
+	// before the next attempt is made. Interceptor may set it before calling invoker, it must call
+	// the previous OnAttempt if it is already set by an outer interceptor.
+	OnAttempt func(attempt CallAttemptInfo)
+}
+
+// CallAttemptInfo describes a single attempt of the call.
+type CallAttemptInfo struct {
+	// Replicaset is a name of replicaset the attempt has been sent to.
+	// It is empty if the bucket has not been routed.
+	Replicaset string
+	// Err is an error of the attempt, nil if the attempt has succeeded.
+	Err error
+}
+
+// CallInvoker performs the call described by info. BucketID, Mode, Fnc and Args of info may be
+// changed by interceptor before invoker is called.
+// The reply is VshardRouterCallResp for Router.Call and map[string]T for RouterMapCallRW[T] (nil for RouterMapCallRWReduce).
+type CallInvoker func(ctx context.Context, info *CallInfo) (reply interface{}, err error)
+
+// CallInterceptor intercepts Router.Call (and all methods based on it, e.g. Router.Do) and map-reduce calls
+// (RouterMapCallRW and the other RouterMap* functions) in the style of gRPC unary interceptors.
+// It must call invoker to perform the call. Router.CallBatch is not intercepted, since a batch doesn't fit
+// CallInfo: it has many bucket identifiers and arguments.
+type CallInterceptor func(ctx context.Context, info *CallInfo, invoker CallInvoker) (reply interface{}, err error)
+
+func (info *CallInfo) addAttempt(rsName string, err error) {
+	if info == nil {
+		return
+	}
+
+	attempt := CallAttemptInfo{Replicaset: rsName, Err: err}
+	info.Attempts = append(info.Attempts, attempt)
+
+	if info.OnAttempt != nil {
+		info.OnAttempt(attempt)
+	}
+}
+
+// interceptedReply converts the reply of Config.Interceptors to the reply type of the call.
+// An interceptor may return a reply of another type (e.g. nil), it's an error unless the interceptor
+// has returned an error too.
+func interceptedReply[T any](reply interface{}, err error) (T, error) {
+	result, ok := reply.(T)
+	if !ok && err == nil {
+		err = fmt.Errorf("interceptor returned %T, expected %T", reply, result)
+	}
+
+	return result, err
+}
+
+// intercept calls invoker through the chain of Config.Interceptors, the first interceptor is the outermost one.
+func (r *Router) intercept(ctx context.Context, info *CallInfo, invoker CallInvoker) (interface{}, error) {
+	interceptors := r.cfg.Interceptors
+
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+
+		invoker = func(ctx context.Context, info *CallInfo) (interface{}, error) {
+			return interceptor(ctx, info, next)
+		}
+	}
+
+	return invoker(ctx, info)
+}
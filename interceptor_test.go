@@ -0,0 +1,102 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+func TestRouter_Call_Interceptors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	wrongBucketErr := StorageCallVShardError{
+		BucketID:    1,
+		Name:        VShardErrNameWrongBucket,
+		Code:        VShardErrCodeWrongBucket,
+		Destination: "replicaset_1",
+	}
+
+	mPool := mockpool.NewPooler(t)
+	mPool.On("Do", mock.Anything, pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Once()
+	mPool.On("Do", mock.Anything, pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+	var (
+		order    []string
+		info     *CallInfo
+		reply    interface{}
+		observed []CallAttemptInfo
+	)
+
+	router := newTestRouter(mPool)
+	router.cfg.Interceptors = []CallInterceptor{
+		func(ctx context.Context, callInfo *CallInfo, invoker CallInvoker) (interface{}, error) {
+			order = append(order, "first")
+
+			resp, err := invoker(ctx, callInfo)
+			info, reply = callInfo, resp
+
+			return resp, err
+		},
+		func(ctx context.Context, callInfo *CallInfo, invoker CallInvoker) (interface{}, error) {
+			order = append(order, "second")
+
+			callInfo.Args = []interface{}{"token", callInfo.Args}
+			callInfo.OnAttempt = func(attempt CallAttemptInfo) {
+				// the attempt is observed before the next one is made
+				require.Len(t, callInfo.Attempts, len(observed)+1)
+
+				observed = append(observed, attempt)
+			}
+
+			return invoker(ctx, callInfo)
+		},
+	}
+
+	resp, err := router.CallRW(ctx, 1, "echo", []interface{}{"arg"}, CallOpts{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"first", "second"}, order)
+	require.Equal(t, resp, reply)
+
+	require.Equal(t, uint64(1), info.BucketID)
+	require.Equal(t, CallModeRW, info.Mode)
+	require.Equal(t, "echo", info.Fnc)
+	require.Equal(t, []interface{}{"token", []interface{}{"arg"}}, info.Args)
+	require.False(t, info.MapReduce)
+
+	require.Len(t, info.Attempts, 2)
+	require.Equal(t, "replicaset_1", info.Attempts[0].Replicaset)
+
+	var vshardError *StorageCallVShardError
+	require.ErrorAs(t, info.Attempts[0].Err, &vshardError)
+	require.Equal(t, CallAttemptInfo{Replicaset: "replicaset_1"}, info.Attempts[1])
+	require.Equal(t, info.Attempts, observed)
+}
+
+func TestRouter_Call_InterceptorReply(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	router := newTestRouter(mockpool.NewPooler(t))
+	router.cfg.Interceptors = []CallInterceptor{
+		func(context.Context, *CallInfo, CallInvoker) (interface{}, error) {
+			// short-circuit the call without a reply
+			return nil, nil
+		},
+	}
+
+	_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.ErrorContains(t, err, "interceptor returned <nil>, expected vshard_router.VshardRouterCallResp")
+
+	_, err = RouterMapCallRW[string](router, ctx, "echo", nil, RouterMapCallRWOptions{})
+	require.ErrorContains(t, err, "interceptor returned <nil>, expected map[string]string")
+}
@@ -0,0 +1,335 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrLimitExceeded is returned when a request to replicaset exceeds Config.Limits and is not queued.
+	ErrLimitExceeded = fmt.Errorf("replicaset limit exceeded")
+)
+
+// LimitOpts limits requests to a replicaset.
+type LimitOpts struct {
+	// MaxInFlight is a maximum number of in-flight requests. Default is 0, which means no limit.
+	MaxInFlight int
+	// Rate is a maximum number of requests per second (token bucket rate). Default is 0, which means no limit.
+	Rate float64
+	// Burst is a token bucket size. Default is max(1, Rate).
+	Burst int
+	// Queue makes excess requests wait for their turn until the call timeout expires,
+	// otherwise they are rejected with ErrLimitExceeded immediately.
+	Queue bool
+	// MaxQueueDepth is a maximum number of waiting requests if Queue is set,
+	// the rest are rejected with ErrLimitExceeded. Default is 0, which means no limit.
+	MaxQueueDepth int
+}
+
+// LimitsOpts configures limits for each replicaset.
+type LimitsOpts struct {
+	// Replicaset limits all requests to a replicaset.
+	Replicaset LimitOpts
+	// Modes limit requests to a replicaset with the given CallMode, in addition to Replicaset limits.
+	// Map-reduce requests are limited as CallModeRW, except RouterMapCallRO requests
+	// that are limited as RouterMapCallROOptions.Mode.
+	Modes map[CallMode]LimitOpts
+}
+
+// limiter implements a single LimitOpts, nil limiter doesn't limit anything.
+type limiter struct {
+	opts    LimitOpts
+	onDepth func(depth int)
+
+	// inFlight is a semaphore, nil if there is no MaxInFlight limit.
+	inFlight chan struct{}
+
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+
+	waiting atomic.Int64
+}
+
+func newLimiter(opts LimitOpts, onDepth func(depth int)) *limiter {
+	if opts.MaxInFlight <= 0 && opts.Rate <= 0 {
+		return nil
+	}
+
+	if opts.Burst <= 0 {
+		opts.Burst = int(math.Max(1, opts.Rate))
+	}
+
+	l := &limiter{
+		opts:    opts,
+		onDepth: onDepth,
+		tokens:  float64(opts.Burst),
+		last:    time.Now(),
+	}
+
+	if opts.MaxInFlight > 0 {
+		l.inFlight = make(chan struct{}, opts.MaxInFlight)
+	}
+
+	return l
+}
+
+// reserveToken takes a token from the bucket and returns the time to wait for it.
+// If there are no tokens and the request should not wait, ok is false.
+func (l *limiter) reserveToken(wait bool) (delay time.Duration, ok bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(float64(l.opts.Burst), l.tokens+now.Sub(l.last).Seconds()*l.opts.Rate)
+	l.last = now
+
+	if l.tokens < 1 && !wait {
+		return 0, false
+	}
+
+	l.tokens--
+
+	if l.tokens >= 0 {
+		return 0, true
+	}
+
+	return time.Duration(-l.tokens / l.opts.Rate * float64(time.Second)), true
+}
+
+func (l *limiter) returnToken() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.tokens = math.Min(float64(l.opts.Burst), l.tokens+1)
+}
+
+func (l *limiter) enqueue() bool {
+	depth := l.waiting.Add(1)
+	if l.opts.MaxQueueDepth > 0 && depth > int64(l.opts.MaxQueueDepth) {
+		l.waiting.Add(-1)
+
+		return false
+	}
+
+	if l.onDepth != nil {
+		l.onDepth(int(depth))
+	}
+
+	return true
+}
+
+func (l *limiter) dequeue() {
+	depth := l.waiting.Add(-1)
+
+	if l.onDepth != nil {
+		l.onDepth(int(depth))
+	}
+}
+
+// acquire waits for a permission to send a request. release must be called when the request is done.
+func (l *limiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	if l.opts.Rate > 0 {
+		if err := l.waitToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.inFlight <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	// the rate token of a rejected request is returned, so rejected requests don't burn the rate budget
+	if !l.opts.Queue {
+		l.cancelToken()
+
+		return nil, fmt.Errorf("%w: %d requests are in flight", ErrLimitExceeded, l.opts.MaxInFlight)
+	}
+
+	if !l.enqueue() {
+		l.cancelToken()
+
+		return nil, fmt.Errorf("%w: %d requests are queued", ErrLimitExceeded, l.opts.MaxQueueDepth)
+	}
+	defer l.dequeue()
+
+	select {
+	case l.inFlight <- struct{}{}:
+		return l.release, nil
+	case <-ctx.Done():
+		l.cancelToken()
+
+		return nil, fmt.Errorf("%w: waiting for in-flight requests: %w", ErrLimitExceeded, ctx.Err())
+	}
+}
+
+// cancelToken returns the token taken by acquire if there is a rate limit.
+func (l *limiter) cancelToken() {
+	if l.opts.Rate > 0 {
+		l.returnToken()
+	}
+}
+
+func (l *limiter) waitToken(ctx context.Context) error {
+	delay, ok := l.reserveToken(l.opts.Queue)
+	if !ok {
+		return fmt.Errorf("%w: rate %v per second", ErrLimitExceeded, l.opts.Rate)
+	}
+
+	if delay == 0 {
+		return nil
+	}
+
+	if !l.enqueue() {
+		l.returnToken()
+
+		return fmt.Errorf("%w: %d requests are queued", ErrLimitExceeded, l.opts.MaxQueueDepth)
+	}
+	defer l.dequeue()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.returnToken()
+
+		return fmt.Errorf("%w: waiting for rate limit: %w", ErrLimitExceeded, ctx.Err())
+	}
+}
+
+func (l *limiter) release() {
+	<-l.inFlight
+}
+
+// abort is called instead of release if the request has not been sent, so the rate token is returned as well.
+func (l *limiter) abort() {
+	if l == nil {
+		return
+	}
+
+	if l.inFlight != nil {
+		l.release()
+	}
+
+	l.cancelToken()
+}
+
+// replicasetLimiters are limiters of a single replicaset.
+type replicasetLimiters struct {
+	all   *limiter
+	modes map[CallMode]*limiter
+}
+
+func (r *Router) newReplicasetLimiters(rsName string) *replicasetLimiters {
+	opts := r.cfg.Limits
+	if opts == nil {
+		return nil
+	}
+
+	onDepth := func(mode string) func(depth int) {
+		return func(depth int) {
+			if m, ok := r.metrics().(LimiterMetricsProvider); ok {
+				m.LimiterQueueDepth(rsName, mode, depth)
+			}
+		}
+	}
+
+	limiters := &replicasetLimiters{
+		all:   newLimiter(opts.Replicaset, onDepth("")),
+		modes: make(map[CallMode]*limiter, len(opts.Modes)),
+	}
+
+	for mode, modeOpts := range opts.Modes {
+		if l := newLimiter(modeOpts, onDepth(mode.String())); l != nil {
+			limiters.modes[mode] = l
+		}
+	}
+
+	return limiters
+}
+
+// acquireLimits waits for a permission to send a request with mode to the replicaset according to Config.Limits.
+// release must be called when the request is done.
+func (rs *Replicaset) acquireLimits(ctx context.Context, mode CallMode) (release func(), err error) {
+	if rs.limiters == nil {
+		return func() {}, nil
+	}
+
+	releaseAll, err := rs.limiters.all.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("replicaset %s: %w", rs.info.Name, err)
+	}
+
+	releaseMode, err := rs.limiters.modes[mode].acquire(ctx)
+	if err != nil {
+		rs.limiters.all.abort()
+
+		return nil, fmt.Errorf("replicaset %s, mode %s: %w", rs.info.Name, mode, err)
+	}
+
+	return func() {
+		releaseMode()
+		releaseAll()
+	}, nil
+}
+
+// abortLimits is called instead of release returned by acquireLimits if the request has not been sent.
+func (rs *Replicaset) abortLimits(mode CallMode) {
+	if rs.limiters == nil {
+		return
+	}
+
+	rs.limiters.modes[mode].abort()
+	rs.limiters.all.abort()
+}
+
+// acquireAllLimits acquires limits of all replicasets for map-reduce requests.
+func acquireAllLimits(ctx context.Context, nameToReplicaset map[string]*Replicaset) (release func(), err error) {
+	names := make([]string, 0, len(nameToReplicaset))
+	for name := range nameToReplicaset {
+		names = append(names, name)
+	}
+
+	// acquire limits in the same order to avoid deadlocks between concurrent map-reduce requests
+	sort.Strings(names)
+
+	releases := make([]func(), 0, len(names))
+	release = func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+
+	for i, name := range names {
+		rsRelease, err := nameToReplicaset[name].acquireLimits(ctx, CallModeRW)
+		if err != nil {
+			for _, name := range names[:i] {
+				nameToReplicaset[name].abortLimits(CallModeRW)
+			}
+
+			return nil, err
+		}
+
+		releases = append(releases, rsRelease)
+	}
+
+	return release, nil
+}
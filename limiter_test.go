@@ -0,0 +1,251 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+func TestLimiter_MaxInFlight(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("reject", func(t *testing.T) {
+		t.Parallel()
+
+		l := newLimiter(LimitOpts{MaxInFlight: 1}, nil)
+
+		release, err := l.acquire(ctx)
+		require.NoError(t, err)
+
+		_, err = l.acquire(ctx)
+		require.ErrorIs(t, err, ErrLimitExceeded)
+
+		release()
+
+		release, err = l.acquire(ctx)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		t.Parallel()
+
+		var depth atomic.Int64
+
+		l := newLimiter(LimitOpts{MaxInFlight: 1, Queue: true, MaxQueueDepth: 1}, func(d int) {
+			depth.Store(int64(d))
+		})
+
+		release, err := l.acquire(ctx)
+		require.NoError(t, err)
+
+		acquired := make(chan error)
+
+		go func() {
+			release, err := l.acquire(ctx)
+			if err == nil {
+				release()
+			}
+
+			acquired <- err
+		}()
+
+		require.Eventually(t, func() bool {
+			return depth.Load() == 1
+		}, time.Second, time.Millisecond)
+
+		// the queue is full
+		_, err = l.acquire(ctx)
+		require.ErrorIs(t, err, ErrLimitExceeded)
+
+		release()
+
+		require.NoError(t, <-acquired)
+		require.Equal(t, int64(0), depth.Load())
+
+		// the queued request is limited by context
+		release, err = l.acquire(ctx)
+		require.NoError(t, err)
+		defer release()
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		_, err = l.acquire(timeoutCtx)
+		require.ErrorIs(t, err, ErrLimitExceeded)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("reject", func(t *testing.T) {
+		t.Parallel()
+
+		l := newLimiter(LimitOpts{Rate: 10}, nil)
+
+		// burst is equal to rate by default
+		for i := 0; i < 10; i++ {
+			_, err := l.acquire(ctx)
+			require.NoError(t, err)
+		}
+
+		_, err := l.acquire(ctx)
+		require.ErrorIs(t, err, ErrLimitExceeded)
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		t.Parallel()
+
+		l := newLimiter(LimitOpts{Rate: 20, Burst: 1, Queue: true}, nil)
+
+		start := time.Now()
+
+		for i := 0; i < 3; i++ {
+			_, err := l.acquire(ctx)
+			require.NoError(t, err)
+		}
+
+		require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+
+	t.Run("rejected by in-flight limit", func(t *testing.T) {
+		t.Parallel()
+
+		l := newLimiter(LimitOpts{Rate: 1, Burst: 2, MaxInFlight: 1}, nil)
+
+		release, err := l.acquire(ctx)
+		require.NoError(t, err)
+
+		// the rejected request doesn't burn the rate budget
+		for i := 0; i < 3; i++ {
+			_, err = l.acquire(ctx)
+			require.ErrorIs(t, err, ErrLimitExceeded)
+			require.NotContains(t, err.Error(), "rate")
+		}
+
+		release()
+
+		release, err = l.acquire(ctx)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		t.Parallel()
+
+		require.Nil(t, newLimiter(LimitOpts{Queue: true}, nil))
+	})
+}
+
+func TestRouter_Call_Limits(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	mPool := mockpool.NewPooler(t)
+	mPool.On("Do", mock.Anything, pool.RO).
+		Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+	router := newTestRouter(mPool)
+	router.cfg.Limits = &LimitsOpts{
+		Modes: map[CallMode]LimitOpts{
+			CallModeRW: {MaxInFlight: 1},
+		},
+	}
+
+	rs := router.getNameToReplicaset()["replicaset_1"]
+	rs.limiters = router.newReplicasetLimiters("replicaset_1")
+
+	release, err := rs.acquireLimits(ctx, CallModeRW)
+	require.NoError(t, err)
+	defer release()
+
+	_, err = router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	// other modes are not limited
+	_, err = router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.NoError(t, err)
+}
+
+func TestReplicaset_AcquireLimits_Rejected(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newRouter := func(t *testing.T) *Router {
+		router := newTestMapRouter(10, map[string]Pooler{
+			"rs1": newInstancePoolerMock(t),
+			"rs2": newInstancePoolerMock(t),
+		})
+		router.cfg.Limits = &LimitsOpts{
+			Replicaset: LimitOpts{Rate: 1, Burst: 2},
+			Modes: map[CallMode]LimitOpts{
+				CallModeRW: {MaxInFlight: 1},
+			},
+		}
+
+		for name, rs := range router.getNameToReplicaset() {
+			rs.limiters = router.newReplicasetLimiters(name)
+		}
+
+		return router
+	}
+
+	t.Run("mode limit", func(t *testing.T) {
+		t.Parallel()
+
+		rs := newRouter(t).getNameToReplicaset()["rs1"]
+
+		release, err := rs.acquireLimits(ctx, CallModeRW)
+		require.NoError(t, err)
+		defer release()
+
+		// the requests rejected by the mode limiter don't burn the rate budget of the replicaset
+		for i := 0; i < 3; i++ {
+			_, err = rs.acquireLimits(ctx, CallModeRW)
+			require.ErrorIs(t, err, ErrLimitExceeded)
+			require.NotContains(t, err.Error(), "rate")
+		}
+
+		release, err = rs.acquireLimits(ctx, CallModeRO)
+		require.NoError(t, err)
+		release()
+	})
+
+	t.Run("map-reduce", func(t *testing.T) {
+		t.Parallel()
+
+		nameToReplicaset := newRouter(t).getNameToReplicaset()
+
+		release, err := nameToReplicaset["rs2"].acquireLimits(ctx, CallModeRW)
+		require.NoError(t, err)
+		defer release()
+
+		// rs1 is acquired before rs2, its rate token is returned once rs2 has rejected the request
+		for i := 0; i < 3; i++ {
+			_, err = acquireAllLimits(ctx, nameToReplicaset)
+			require.ErrorIs(t, err, ErrLimitExceeded)
+			require.Contains(t, err.Error(), "replicaset rs2")
+		}
+
+		for i := 0; i < 2; i++ {
+			release, err := nameToReplicaset["rs1"].acquireLimits(ctx, CallModeRO)
+			require.NoError(t, err)
+			release()
+		}
+	})
+}
@@ -0,0 +1,780 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// vshardStorageServiceCall is a storage function that implements map-reduce stages.
+const vshardStorageServiceCall = "vshard.storage._call"
+
+var (
+	// ErrMapCallRef is matched by errors of map-reduce ref stage on a replicaset.
+	ErrMapCallRef = fmt.Errorf("map-reduce ref stage failed")
+	// ErrMapCallMap is matched by errors of map-reduce map stage on a replicaset.
+	ErrMapCallMap = fmt.Errorf("map-reduce map stage failed")
+	// ErrMapCallTimeout is matched by errors of map-reduce stages on a replicaset caused by timeout.
+	ErrMapCallTimeout = fmt.Errorf("map-reduce timeout")
+	// ErrMapCallNotEnoughResults is returned if fewer replicasets than required have succeeded.
+	ErrMapCallNotEnoughResults = fmt.Errorf("map-reduce succeeded on too few replicasets")
+)
+
+// MapCallStage is a stage of map-reduce call.
+type MapCallStage int
+
+const (
+	// MapCallStageRef is a stage that refs buckets of replicasets, so they can't be moved during map stage.
+	MapCallStageRef MapCallStage = iota
+	// MapCallStageMap is a stage that calls user function on replicasets.
+	MapCallStageMap
+)
+
+func (s MapCallStage) String() string {
+	switch s {
+	case MapCallStageRef:
+		return "ref"
+	case MapCallStageMap:
+		return "map"
+	default:
+		return fmt.Sprintf("MapCallStage(%d)", int(s))
+	}
+}
+
+// ReplicasetMapCallError is an error of map-reduce call on a replicaset.
+// It matches ErrMapCallRef or ErrMapCallMap depending on the stage, and ErrMapCallTimeout if the stage has timed out.
+type ReplicasetMapCallError struct {
+	Replicaset string
+	Stage      MapCallStage
+	// Timeout is true if the error is caused by timeout.
+	Timeout bool
+	Err     error
+}
+
+func newReplicasetMapCallError(rsName string, stage MapCallStage, err error) *ReplicasetMapCallError {
+	return &ReplicasetMapCallError{
+		Replicaset: rsName,
+		Stage:      stage,
+		Timeout:    errors.Is(err, context.DeadlineExceeded),
+		Err:        err,
+	}
+}
+
+func (e *ReplicasetMapCallError) Error() string {
+	return fmt.Sprintf("replicaset %s: storage_%s failed: %v", e.Replicaset, e.Stage, e.Err)
+}
+
+func (e *ReplicasetMapCallError) Unwrap() []error {
+	errs := make([]error, 0, 3)
+
+	switch e.Stage {
+	case MapCallStageRef:
+		errs = append(errs, ErrMapCallRef)
+	case MapCallStageMap:
+		errs = append(errs, ErrMapCallMap)
+	}
+
+	if e.Timeout {
+		errs = append(errs, ErrMapCallTimeout)
+	}
+
+	return append(errs, e.Err)
+}
+
+// MapCallResult is a result of map-reduce call on a replicaset.
+type MapCallResult[T any] struct {
+	// Value is a value returned by user function, it is set if Err is nil.
+	Value T
+	// BucketCount is a number of buckets referenced on the replicaset, it is zero if ref stage has failed.
+	BucketCount uint64
+	// Err is *ReplicasetMapCallError if the call has failed on the replicaset.
+	Err error
+}
+
+// RouterMapCallRWPartialOptions sets options for RouterMapCallRWPartial.
+type RouterMapCallRWPartialOptions struct {
+	// Timeout defines timeout for RouterMapCallRWPartial.
+	Timeout time.Duration
+	// MinSuccess is a minimum number of replicasets that must succeed, otherwise
+	// ErrMapCallNotEnoughResults is returned along with the results. Default is 0, which means no minimum.
+	MinSuccess int
+}
+
+// RouterMapCallRWPartial is a map-reduce that returns a result or an error for each replicaset, instead of failing
+// on the first error as RouterMapCallRW does. The function is called only on the replicasets whose buckets have
+// been referenced, so the result of a replicaset is consistent, but the buckets of the failed replicasets are
+// missing in the results. Use MapCallResult.BucketCount to check how many buckets have been covered.
+// A replicaset that can't be referenced at the moment because of rebalancing is retried until the timeout expires.
+// T is a return type of user defined function 'fnc'.
+func RouterMapCallRWPartial[T any](r *Router, ctx context.Context,
+	fnc string, args interface{}, opts RouterMapCallRWPartialOptions,
+) (map[string]MapCallResult[T], error) {
+	if len(r.cfg.Interceptors) == 0 {
+		return routerMapCallRWPartial[T](r, ctx, fnc, args, opts, nil)
+	}
+
+	info := &CallInfo{
+		Mode:      CallModeRW,
+		MapReduce: true,
+		Fnc:       fnc,
+		Args:      args,
+	}
+
+	reply, err := r.intercept(ctx, info, func(ctx context.Context, info *CallInfo) (interface{}, error) {
+		return routerMapCallRWPartial[T](r, ctx, info.Fnc, info.Args, opts, info)
+	})
+
+	return interceptedReply[map[string]MapCallResult[T]](reply, err)
+}
+
+// routerMapCallRWPartial implements RouterMapCallRWPartial, info is nil if there are no interceptors.
+func routerMapCallRWPartial[T any](r *Router, ctx context.Context,
+	fnc string, args interface{}, opts RouterMapCallRWPartialOptions, info *CallInfo,
+) (map[string]MapCallResult[T], error) {
+	if err := r.beginRequest(); err != nil {
+		return nil, err
+	}
+	defer r.endRequest()
+
+	timeout := callTimeoutDefault
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+
+	timeStart := time.Now()
+	refID := r.refID.Add(1)
+
+	nameToReplicasetRef := r.getNameToReplicaset()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	releaseLimits, err := acquireAllLimits(ctx, nameToReplicasetRef)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseLimits()
+
+	nameToResult := make(map[string]MapCallResult[T], len(nameToReplicasetRef))
+
+	fail := func(rsName string, stage MapCallStage, err error) {
+		err = newReplicasetMapCallError(rsName, stage, err)
+		info.addAttempt(rsName, err)
+
+		result := nameToResult[rsName]
+		result.Err = err
+		nameToResult[rsName] = result
+	}
+
+	done := func(err error) (map[string]MapCallResult[T], error) {
+		r.metrics().RequestDuration(time.Since(timeStart), fnc, err == nil, true)
+
+		return nameToResult, err
+	}
+
+	// ref stage
+
+	defer r.storageUnrefAll(ctx, nameToReplicasetRef, refID)
+
+	nameToReplicasetReferenced := r.storageRefEachRetry(ctx, nameToReplicasetRef, refID,
+		func(rsName string, bucketCount uint64, err error) {
+			if err != nil {
+				fail(rsName, MapCallStageRef, err)
+				return
+			}
+
+			nameToResult[rsName] = MapCallResult[T]{BucketCount: bucketCount}
+		})
+
+	if len(nameToReplicasetReferenced) < opts.MinSuccess {
+		return done(fmt.Errorf("%w: %d of %d replicasets referenced, %d required",
+			ErrMapCallNotEnoughResults, len(nameToReplicasetReferenced), len(nameToReplicasetRef), opts.MinSuccess))
+	}
+
+	// map stage
+
+	rsFutures := storageMapAll(ctx, nameToReplicasetReferenced, refID, fnc, args)
+
+	var succeeded int
+
+	for _, rsFuture := range rsFutures {
+		storageMapResponse := storageMapResponseProto[T]{}
+
+		if err := getTyped(ctx, rsFuture.future, &storageMapResponse); err != nil {
+			fail(rsFuture.name, MapCallStageMap, err)
+			continue
+		}
+
+		if !storageMapResponse.ok {
+			fail(rsFuture.name, MapCallStageMap, storageMapResponse.err)
+			continue
+		}
+
+		info.addAttempt(rsFuture.name, nil)
+
+		result := nameToResult[rsFuture.name]
+		result.Value = storageMapResponse.value
+		nameToResult[rsFuture.name] = result
+		succeeded++
+	}
+
+	if succeeded < opts.MinSuccess {
+		return done(fmt.Errorf("%w: %d of %d replicasets succeeded, %d required",
+			ErrMapCallNotEnoughResults, succeeded, len(nameToReplicasetRef), opts.MinSuccess))
+	}
+
+	return done(nil)
+}
+
+// storageRefEachRetry performs the ref stage of RouterMapCallRWPartial: unlike storageRefAllRetry, a failure of
+// a replicaset doesn't fail the others. A replicaset that can't be referenced at the moment is retried until ctx
+// is done. onResult is called once for every replicaset, the referenced replicasets are returned.
+func (r *Router) storageRefEachRetry(ctx context.Context, nameToReplicasetRef map[string]*Replicaset, refID int64,
+	onResult func(rsName string, bucketCount uint64, err error)) map[string]*Replicaset {
+	nameToReplicasetReferenced := make(map[string]*Replicaset, len(nameToReplicasetRef))
+	pending := nameToReplicasetRef
+
+	for len(pending) > 0 {
+		nameToReplicasetRetry := make(map[string]*Replicaset)
+		nameToRetryErr := make(map[string]error)
+		var retryReasons []string
+
+		for _, rsFuture := range storageRefSendAll(ctx, pending, refID) {
+			bucketCount, retryReason, err := storageRefWait(ctx, rsFuture)
+
+			switch {
+			case err == nil:
+				nameToReplicasetReferenced[rsFuture.name] = pending[rsFuture.name]
+				onResult(rsFuture.name, bucketCount, nil)
+			case retryReason != "":
+				r.log().Debugf(ctx, "Retry map-reduce ref stage on %s: %v", retryReason, err)
+
+				nameToReplicasetRetry[rsFuture.name] = pending[rsFuture.name]
+				nameToRetryErr[rsFuture.name] = err
+				retryReasons = append(retryReasons, retryReason)
+			default:
+				onResult(rsFuture.name, 0, err)
+			}
+		}
+
+		if len(nameToReplicasetRetry) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			for name, err := range nameToRetryErr {
+				onResult(name, 0, fmt.Errorf("%w (ref stage has been retried until %w)", err, ctx.Err()))
+			}
+
+			return nameToReplicasetReferenced
+		case <-time.After(storageRefRetryPause):
+		}
+
+		for _, retryReason := range retryReasons {
+			r.metrics().RetryOnCall(retryReason)
+		}
+
+		pending = nameToReplicasetRetry
+	}
+
+	return nameToReplicasetReferenced
+}
+
+// storageUnrefTimeout is a timeout of storage_unref. It doesn't depend on the map-reduce timeout,
+// so the refs are released even if the map-reduce has timed out or has been canceled.
+const storageUnrefTimeout = time.Second
+
+// storageUnrefAll releases the ref of map-reduce call on the replicasets, otherwise the refs stay on storages
+// until their timeout and block rebalancing. It doesn't wait for the responses, so the caller is not delayed
+// by storage_unref: they are checked in background, failures are logged and reported to
+// StorageUnrefMetricsProvider.StorageUnrefError.
+func (r *Router) storageUnrefAll(ctx context.Context, nameToReplicaset map[string]*Replicaset, refID int64) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storageUnrefTimeout)
+
+	storageUnrefReq := tarantool.NewCallRequest(vshardStorageServiceCall).
+		Context(ctx).
+		Args([]interface{}{"storage_unref", refID})
+
+	nameToReplicasetActual := r.getNameToReplicaset()
+	rsFutures := make([]replicasetFuture, 0, len(nameToReplicaset))
+
+	for name, rs := range nameToReplicaset {
+		// A ref belongs to the storage session, so it can be released only through the same connections.
+		// If the replicaset has been removed or replaced, its connections are closed and storage drops the ref.
+		if nameToReplicasetActual[name] != rs {
+			r.log().Debugf(ctx, "Skip storage_unref %d on removed replicaset %s", refID, name)
+			continue
+		}
+
+		rsFutures = append(rsFutures, replicasetFuture{
+			name:   name,
+			future: rs.do(storageUnrefReq, pool.RW),
+		})
+	}
+
+	go func() {
+		defer cancel()
+
+		r.storageUnrefWait(ctx, rsFutures, refID)
+	}()
+}
+
+func (r *Router) storageUnrefWait(ctx context.Context, rsFutures []replicasetFuture, refID int64) {
+	for _, rsFuture := range rsFutures {
+		var storageUnrefResponse []interface{}
+
+		// proto for 'storage_unref' method: true or nil, err
+		err := getTyped(ctx, rsFuture.future, &storageUnrefResponse)
+		if err == nil && len(storageUnrefResponse) > 1 && storageUnrefResponse[0] == nil {
+			err = fmt.Errorf("%v", storageUnrefResponse[1])
+		}
+
+		if err != nil {
+			r.log().Errorf(ctx, "storage_unref %d failed on %s, the ref is kept until its timeout: %v",
+				refID, rsFuture.name, err)
+
+			if m, ok := r.metrics().(StorageUnrefMetricsProvider); ok {
+				m.StorageUnrefError(rsFuture.name)
+			}
+		}
+	}
+}
+
+// getTyped waits for the future and decodes the response. It returns as soon as ctx is done,
+// so map-reduce is aborted promptly even if the request itself is not canceled.
+// The error wraps ctx.Err() if the request has failed because ctx is done.
+func getTyped(ctx context.Context, future *tarantool.Future, result interface{}) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-future.WaitChan():
+		err := future.GetTyped(result)
+		if err != nil && ctx.Err() != nil {
+			// the connection cancels the request with an error that doesn't wrap ctx.Err()
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+
+		return err
+	}
+}
+
+// RouterMapPartCallRW is a consistent Map-Reduce over the given buckets, an equivalent of router.map_part_callrw
+// in lua vshard. The buckets are grouped by replicasets according to the route map, and only the replicasets
+// owning them are referenced and called. If some buckets have been moved, the route map is updated and the moved
+// buckets are referenced on their new replicasets, until all of them are referenced or the timeout expires.
+// The function is called with the sorted bucket identifiers owned by the replicaset as the first argument,
+// followed by args. Storages must support map_part_callrw (storage_ref_make_with_buckets and
+// storage_ref_check_with_buckets service functions).
+// T is a return type of user defined function 'fnc'.
+func RouterMapPartCallRW[T any](r *Router, ctx context.Context, bucketIDs []uint64,
+	fnc string, args []interface{}, opts RouterMapCallRWOptions,
+) (map[string]T, error) {
+	if len(r.cfg.Interceptors) == 0 {
+		return routerMapPartCallRW[T](r, ctx, bucketIDs, fnc, args, opts, nil)
+	}
+
+	info := &CallInfo{
+		Mode:      CallModeRW,
+		MapReduce: true,
+		Fnc:       fnc,
+		Args:      args,
+	}
+
+	reply, err := r.intercept(ctx, info, func(ctx context.Context, info *CallInfo) (interface{}, error) {
+		args, _ := info.Args.([]interface{})
+
+		return routerMapPartCallRW[T](r, ctx, bucketIDs, info.Fnc, args, opts, info)
+	})
+
+	return interceptedReply[map[string]T](reply, err)
+}
+
+// routerMapPartCallRW implements RouterMapPartCallRW, info is nil if there are no interceptors.
+func routerMapPartCallRW[T any](r *Router, ctx context.Context, bucketIDs []uint64,
+	fnc string, args []interface{}, opts RouterMapCallRWOptions, info *CallInfo,
+) (map[string]T, error) {
+	if err := r.beginRequest(); err != nil {
+		return nil, err
+	}
+	defer r.endRequest()
+
+	timeout := callTimeoutDefault
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+
+	timeStart := time.Now()
+	refID := r.refID.Add(1)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+
+	fail := func(err error) (map[string]T, error) {
+		r.metrics().RequestDuration(time.Since(timeStart), fnc, false, true)
+
+		return nil, err
+	}
+
+	pending := make([]uint64, 0, len(bucketIDs))
+	seen := make(map[uint64]struct{}, len(bucketIDs))
+
+	for _, bucketID := range bucketIDs {
+		if bucketID < 1 || r.cfg.TotalBucketCount < bucketID {
+			return fail(fmt.Errorf("bucket id is out of range: %d (total %d)", bucketID, r.cfg.TotalBucketCount))
+		}
+
+		if _, ok := seen[bucketID]; !ok {
+			seen[bucketID] = struct{}{}
+			pending = append(pending, bucketID)
+		}
+	}
+
+	// the buckets may move to any replicaset during the ref stage, so the limits of all the replicasets are acquired
+	// before it, in the same order as other map-reduce requests do
+	releaseLimits, err := acquireAllLimits(ctx, r.getNameToReplicaset())
+	if err != nil {
+		return fail(err)
+	}
+	defer releaseLimits()
+
+	// the replicasets the ref has been sent to, they are unreferenced even if the ref has failed
+	nameToReplicasetRef := make(map[string]*Replicaset)
+	// the replicasets the ref has been made on
+	nameToReplicasetReferenced := make(map[string]*Replicaset)
+	nameToBuckets := make(map[string][]uint64)
+
+	// the map is filled during the ref stage, so all the replicasets added there are unreferenced
+	defer r.storageUnrefAll(ctx, nameToReplicasetRef, refID)
+
+	// ref stage
+
+	for len(pending) > 0 {
+		rsToBuckets := make(map[*Replicaset][]uint64)
+
+		for _, bucketID := range pending {
+			rs, err := r.Route(ctx, bucketID)
+			if err != nil {
+				return fail(fmt.Errorf("cant resolve bucket %d: %w", bucketID, err))
+			}
+
+			rsToBuckets[rs] = append(rsToBuckets[rs], bucketID)
+		}
+
+		rsFutures := make([]replicasetFuture, 0, len(rsToBuckets))
+		nameToPending := make(map[string][]uint64, len(rsToBuckets))
+
+		for rs, buckets := range rsToBuckets {
+			name := rs.info.Name
+			slices.Sort(buckets)
+
+			var storageRefArgs []interface{}
+			if _, ok := nameToReplicasetReferenced[name]; ok {
+				// the replicaset is already referenced, just check the buckets
+				storageRefArgs = []interface{}{"storage_ref_check_with_buckets", refID, buckets}
+			} else {
+				storageRefArgs = []interface{}{"storage_ref_make_with_buckets", refID, time.Until(deadline).Seconds(), buckets}
+			}
+
+			storageRefReq := tarantool.NewCallRequest(vshardStorageServiceCall).
+				Context(ctx).
+				Args(storageRefArgs)
+
+			nameToReplicasetRef[name] = rs
+			nameToPending[name] = buckets
+
+			rsFutures = append(rsFutures, replicasetFuture{
+				name:   name,
+				future: rs.do(storageRefReq, pool.RW),
+			})
+		}
+
+		pending = pending[0:0]
+
+		for _, rsFuture := range rsFutures {
+			var storageRefResponse storageRefWithBucketsResponseProto
+
+			err := getTyped(ctx, rsFuture.future, &storageRefResponse)
+			if err == nil {
+				err = storageRefResponse.err
+			}
+
+			if err != nil {
+				err = newReplicasetMapCallError(rsFuture.name, MapCallStageRef, err)
+				info.addAttempt(rsFuture.name, err)
+
+				return fail(err)
+			}
+
+			moved := make(map[uint64]struct{}, len(storageRefResponse.moved))
+
+			for _, bucket := range storageRefResponse.moved {
+				moved[bucket.ID] = struct{}{}
+
+				r.BucketReset(bucket.ID)
+
+				if bucket.Destination != "" {
+					if destinationName, ok := r.replicasetNameByDestination(bucket.Destination); ok {
+						_, _ = r.BucketSet(bucket.ID, destinationName)
+					}
+				}
+
+				pending = append(pending, bucket.ID)
+			}
+
+			for _, bucketID := range nameToPending[rsFuture.name] {
+				if _, ok := moved[bucketID]; !ok {
+					nameToBuckets[rsFuture.name] = append(nameToBuckets[rsFuture.name], bucketID)
+				}
+			}
+
+			if storageRefResponse.referenced {
+				nameToReplicasetReferenced[rsFuture.name] = nameToReplicasetRef[rsFuture.name]
+			}
+		}
+
+		if len(pending) > 0 {
+			// give the rebalancer time to finish the moves, as storageRefAllRetry does
+			select {
+			case <-ctx.Done():
+				return fail(fmt.Errorf("%w: %d buckets are not referenced", ErrMapCallTimeout, len(pending)))
+			case <-time.After(storageRefRetryPause):
+			}
+
+			r.metrics().RetryOnCall("bucket_migrate")
+		}
+	}
+
+	// map stage
+
+	rsFutures := make([]replicasetFuture, 0, len(nameToBuckets))
+
+	for name, buckets := range nameToBuckets {
+		slices.Sort(buckets)
+
+		storageMapReq := tarantool.NewCallRequest(vshardStorageServiceCall).
+			Context(ctx).
+			Args([]interface{}{"storage_map", refID, fnc, append([]interface{}{buckets}, args...)})
+
+		rsFutures = append(rsFutures, replicasetFuture{
+			name:   name,
+			future: nameToReplicasetReferenced[name].do(storageMapReq, pool.RW),
+		})
+	}
+
+	nameToResult := make(map[string]T, len(rsFutures))
+
+	for _, rsFuture := range rsFutures {
+		storageMapResponse := storageMapResponseProto[T]{}
+
+		err := getTyped(ctx, rsFuture.future, &storageMapResponse)
+		if err == nil && !storageMapResponse.ok {
+			err = storageMapResponse.err
+		}
+
+		if err != nil {
+			err = newReplicasetMapCallError(rsFuture.name, MapCallStageMap, err)
+			info.addAttempt(rsFuture.name, err)
+
+			return fail(err)
+		}
+
+		info.addAttempt(rsFuture.name, nil)
+
+		nameToResult[rsFuture.name] = storageMapResponse.value
+	}
+
+	r.metrics().RequestDuration(time.Since(timeStart), fnc, true, true)
+
+	return nameToResult, nil
+}
+
+// storageMovedBucket is a bucket that is not stored on the replicaset, as returned by
+// storage_ref_make_with_buckets and storage_ref_check_with_buckets.
+type storageMovedBucket struct {
+	ID uint64 `msgpack:"id"`
+	// Destination is a name or UUID of the replicaset the bucket has been moved to, it is empty if unknown.
+	Destination string `msgpack:"dst"`
+}
+
+type storageRefWithBucketsResponseProto struct {
+	err error
+	// referenced is true if the ref has been made, i.e. not all the buckets have been moved
+	referenced bool
+	moved      []storageMovedBucket
+}
+
+func (r *storageRefWithBucketsResponseProto) DecodeMsgpack(d *msgpack.Decoder) error {
+	// proto for 'storage_ref_make_with_buckets' and 'storage_ref_check_with_buckets' methods:
+	// {rid = rid, moved = {{id = bucket_id, dst = destination}, ...}} or nil, err;
+	// rid is nil if the ref has not been made.
+	respArrayLen, err := d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	if respArrayLen <= 0 {
+		return fmt.Errorf("protocol violation: invalid array length: %d", respArrayLen)
+	}
+
+	code, err := d.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if code == msgpcode.Nil {
+		err = d.DecodeNil()
+		if err != nil {
+			return err
+		}
+
+		if respArrayLen != 2 {
+			return fmt.Errorf("protocol violation: length is %d on error case", respArrayLen)
+		}
+
+		r.err, err = decodeStorageRefError(d)
+
+		return err
+	}
+
+	var result struct {
+		RID   interface{}          `msgpack:"rid"`
+		Moved []storageMovedBucket `msgpack:"moved"`
+	}
+
+	if err := d.Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode storage ref result: %w", err)
+	}
+
+	r.referenced = result.RID != nil
+	r.moved = result.Moved
+
+	return nil
+}
+
+// RouterMapCallRWReduce is RouterMapCallRW that doesn't collect the results in memory: each replicaset result is
+// decoded and passed to reduce as soon as its response is received, so the results come in the order of responses.
+// If reduce returns false, the rest of results are skipped and RouterMapCallRWReduce returns nil.
+// reduce is called in the caller goroutine, so it may fold results into local variables without synchronization.
+// The reply of CallInvoker is nil for RouterMapCallRWReduce.
+// T is a return type of user defined function 'fnc'.
+func RouterMapCallRWReduce[T any](r *Router, ctx context.Context,
+	fnc string, args interface{}, opts RouterMapCallRWOptions, reduce func(rsName string, value T) bool,
+) error {
+	if len(r.cfg.Interceptors) == 0 {
+		return routerMapCallRWReduce[T](r, ctx, fnc, args, opts, reduce, nil)
+	}
+
+	info := &CallInfo{
+		Mode:      CallModeRW,
+		MapReduce: true,
+		Fnc:       fnc,
+		Args:      args,
+	}
+
+	_, err := r.intercept(ctx, info, func(ctx context.Context, info *CallInfo) (interface{}, error) {
+		return nil, routerMapCallRWReduce[T](r, ctx, info.Fnc, info.Args, opts, reduce, info)
+	})
+
+	return err
+}
+
+// routerMapCallRWReduce implements RouterMapCallRWReduce, info is nil if there are no interceptors.
+func routerMapCallRWReduce[T any](r *Router, ctx context.Context,
+	fnc string, args interface{}, opts RouterMapCallRWOptions, reduce func(rsName string, value T) bool, info *CallInfo,
+) error {
+	if err := r.beginRequest(); err != nil {
+		return err
+	}
+	defer r.endRequest()
+
+	timeout := callTimeoutDefault
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+
+	timeStart := time.Now()
+
+	nameToReplicasetRef := r.getNameToReplicaset()
+
+	// the requests that are not completed yet are canceled on return
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fail := func(err error) error {
+		r.metrics().RequestDuration(time.Since(timeStart), fnc, false, true)
+
+		return err
+	}
+
+	releaseLimits, err := acquireAllLimits(ctx, nameToReplicasetRef)
+	if err != nil {
+		return fail(err)
+	}
+	defer releaseLimits()
+
+	// ref stage
+
+	refID, err := r.storageRefAllRetry(ctx, nameToReplicasetRef, info)
+	if err != nil {
+		return fail(err)
+	}
+	defer r.storageUnrefAll(ctx, nameToReplicasetRef, refID)
+
+	// map stage
+
+	rsFutures := storageMapAll(ctx, nameToReplicasetRef, refID, fnc, args)
+
+	// the channel is buffered, so the goroutines don't leak if reduce stops early
+	completed := make(chan replicasetFuture, len(rsFutures))
+
+	for _, rsFuture := range rsFutures {
+		go func(rsFuture replicasetFuture) {
+			<-rsFuture.future.WaitChan()
+			completed <- rsFuture
+		}(rsFuture)
+	}
+
+	for range rsFutures {
+		var rsFuture replicasetFuture
+
+		select {
+		case <-ctx.Done():
+			return fail(fmt.Errorf("%w: storage_map: %w", ErrMapCallMap, ctx.Err()))
+		case rsFuture = <-completed:
+		}
+
+		storageMapResponse := storageMapResponseProto[T]{}
+
+		err := rsFuture.future.GetTyped(&storageMapResponse)
+		if err == nil && !storageMapResponse.ok {
+			err = storageMapResponse.err
+		}
+
+		if err != nil {
+			err = newReplicasetMapCallError(rsFuture.name, MapCallStageMap, err)
+			info.addAttempt(rsFuture.name, err)
+
+			return fail(err)
+		}
+
+		info.addAttempt(rsFuture.name, nil)
+
+		if !reduce(rsFuture.name, storageMapResponse.value) {
+			break
+		}
+	}
+
+	r.metrics().RequestDuration(time.Since(timeStart), fnc, true, true)
+
+	return nil
+}
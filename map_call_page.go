@@ -0,0 +1,328 @@
+package vshard_router //nolint:revive
+
+import (
+	"container/heap"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrInvalidPageCursor is returned by RouterMapCallRWPage if the cursor can't be decoded.
+var ErrInvalidPageCursor = fmt.Errorf("invalid page cursor")
+
+// RouterMapCallRWPageOptions sets options for RouterMapCallRWPage.
+type RouterMapCallRWPageOptions struct {
+	// Timeout defines timeout for RouterMapCallRWPage.
+	Timeout time.Duration
+	// Limit is a maximum number of items in the page, it must be positive.
+	Limit int
+	// Cursor is MapCallPage.Cursor of the previous page, it is empty for the first page.
+	Cursor string
+}
+
+// MapCallPage is a page of items returned by RouterMapCallRWPage.
+type MapCallPage[T any] struct {
+	// Items are sorted items of all the replicasets.
+	Items []T
+	// Cursor is an opaque continuation cursor that records the position of every replicaset.
+	// It is empty if there are no more items.
+	Cursor string
+}
+
+// pageCursor is the content of MapCallPage.Cursor.
+type pageCursor struct {
+	// After is the last item of a replicaset returned in pages, encoded back from T.
+	After map[string]msgpack.RawMessage `msgpack:"after"`
+	// Done are the replicasets that have no more items.
+	Done []string `msgpack:"done"`
+}
+
+func decodePageCursor(cursor string) (pageCursor, error) {
+	var decoded pageCursor
+
+	if cursor == "" {
+		return decoded, nil
+	}
+
+	bts, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		err = msgpack.Unmarshal(bts, &decoded)
+	}
+
+	if err != nil {
+		return decoded, fmt.Errorf("%w: %v", ErrInvalidPageCursor, err)
+	}
+
+	return decoded, nil
+}
+
+func (c pageCursor) encode() (string, error) {
+	bts, err := msgpack.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bts), nil
+}
+
+// RouterMapCallRWPage selects the page of the first opts.Limit items across all replicasets ordered by compare,
+// starting after opts.Cursor. The function is called on every replicaset (except the exhausted ones) as
+// RouterMapCallRW does, with the replicaset position and the limit prepended to args: fnc(after, limit, ...).
+// 'after' is nil for the first page, otherwise it is the last item of the replicaset returned in the previous pages
+// (encoded back from T, so T must keep the fields the function needs to find the position).
+// The function must return an array of at most 'limit' items following 'after', sorted in the order of compare.
+// The results are merged with a k-way merge, and the page is returned with a cursor for the next page.
+// Each page is consistent, but buckets may be moved between the pages, so the items of moved buckets
+// may be missed or repeated.
+// T is a type of items returned by user defined function 'fnc'.
+func RouterMapCallRWPage[T any](r *Router, ctx context.Context,
+	fnc string, args []interface{}, compare func(a, b T) int, opts RouterMapCallRWPageOptions,
+) (MapCallPage[T], error) {
+	if len(r.cfg.Interceptors) == 0 {
+		return routerMapCallRWPage[T](r, ctx, fnc, args, compare, opts, nil)
+	}
+
+	info := &CallInfo{
+		Mode:      CallModeRW,
+		MapReduce: true,
+		Fnc:       fnc,
+		Args:      args,
+	}
+
+	reply, err := r.intercept(ctx, info, func(ctx context.Context, info *CallInfo) (interface{}, error) {
+		args, _ := info.Args.([]interface{})
+
+		return routerMapCallRWPage[T](r, ctx, info.Fnc, args, compare, opts, info)
+	})
+
+	return interceptedReply[MapCallPage[T]](reply, err)
+}
+
+// routerMapCallRWPage implements RouterMapCallRWPage, info is nil if there are no interceptors.
+func routerMapCallRWPage[T any](r *Router, ctx context.Context,
+	fnc string, args []interface{}, compare func(a, b T) int, opts RouterMapCallRWPageOptions, info *CallInfo,
+) (MapCallPage[T], error) {
+	if opts.Limit <= 0 {
+		return MapCallPage[T]{}, fmt.Errorf("page limit must be positive, got %d", opts.Limit)
+	}
+
+	cursor, err := decodePageCursor(opts.Cursor)
+	if err != nil {
+		return MapCallPage[T]{}, err
+	}
+
+	if err := r.beginRequest(); err != nil {
+		return MapCallPage[T]{}, err
+	}
+	defer r.endRequest()
+
+	timeout := callTimeoutDefault
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+
+	timeStart := time.Now()
+
+	nameToReplicasetRef := r.getNameToReplicaset()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	fail := func(err error) (MapCallPage[T], error) {
+		r.metrics().RequestDuration(time.Since(timeStart), fnc, false, true)
+
+		return MapCallPage[T]{}, err
+	}
+
+	releaseLimits, err := acquireAllLimits(ctx, nameToReplicasetRef)
+	if err != nil {
+		return fail(err)
+	}
+	defer releaseLimits()
+
+	// ref stage
+
+	refID, err := r.storageRefAllRetry(ctx, nameToReplicasetRef, info)
+	if err != nil {
+		return fail(err)
+	}
+	defer r.storageUnrefAll(ctx, nameToReplicasetRef, refID)
+
+	// map stage
+
+	rsFutures := make([]replicasetFuture, 0, len(nameToReplicasetRef))
+
+	for name, rs := range nameToReplicasetRef {
+		if slices.Contains(cursor.Done, name) {
+			continue
+		}
+
+		var after interface{}
+		if raw, ok := cursor.After[name]; ok {
+			after = raw
+		}
+
+		storageMapReq := tarantool.NewCallRequest(vshardStorageServiceCall).
+			Context(ctx).
+			Args([]interface{}{"storage_map", refID, fnc, append([]interface{}{after, opts.Limit}, args...)})
+
+		rsFutures = append(rsFutures, replicasetFuture{
+			name:   name,
+			future: rs.do(storageMapReq, pool.RW),
+		})
+	}
+
+	merge := &pageMerge[T]{compare: compare}
+
+	for _, rsFuture := range rsFutures {
+		storageMapResponse := storageMapResponseProto[[]T]{}
+
+		err := getTyped(ctx, rsFuture.future, &storageMapResponse)
+		if err == nil && !storageMapResponse.ok {
+			err = storageMapResponse.err
+		}
+
+		if err != nil {
+			err = newReplicasetMapCallError(rsFuture.name, MapCallStageMap, err)
+			info.addAttempt(rsFuture.name, err)
+
+			return fail(err)
+		}
+
+		info.addAttempt(rsFuture.name, nil)
+
+		merge.sources = append(merge.sources, pageSource[T]{
+			name:  rsFuture.name,
+			items: storageMapResponse.value,
+			// the replicaset has returned all the items it has
+			exhausted: len(storageMapResponse.value) < opts.Limit,
+		})
+	}
+
+	page, next, err := merge.page(cursor, opts.Limit)
+	if err != nil {
+		return fail(err)
+	}
+
+	// the cursor is empty if all the replicasets of the current topology are exhausted
+	for name := range nameToReplicasetRef {
+		if slices.Contains(next.Done, name) {
+			continue
+		}
+
+		page.Cursor, err = next.encode()
+		if err != nil {
+			return fail(err)
+		}
+
+		break
+	}
+
+	r.metrics().RequestDuration(time.Since(timeStart), fnc, true, true)
+
+	return page, nil
+}
+
+// pageSource is the sorted items returned by a replicaset.
+type pageSource[T any] struct {
+	name      string
+	items     []T
+	exhausted bool
+	// consumed is a number of items taken into the page
+	consumed int
+}
+
+// pageMerge is a k-way merge of sources, it implements heap.Interface over the sources that have items left.
+type pageMerge[T any] struct {
+	compare func(a, b T) int
+	sources []pageSource[T]
+	heap    []int
+}
+
+func (m *pageMerge[T]) Len() int { return len(m.heap) }
+
+func (m *pageMerge[T]) Less(i, j int) bool {
+	a, b := &m.sources[m.heap[i]], &m.sources[m.heap[j]]
+
+	if c := m.compare(a.items[a.consumed], b.items[b.consumed]); c != 0 {
+		return c < 0
+	}
+
+	// equal items are ordered by replicasets to make pages stable
+	return a.name < b.name
+}
+
+func (m *pageMerge[T]) Swap(i, j int) { m.heap[i], m.heap[j] = m.heap[j], m.heap[i] }
+
+func (m *pageMerge[T]) Push(x interface{}) { m.heap = append(m.heap, x.(int)) }
+
+func (m *pageMerge[T]) Pop() interface{} {
+	x := m.heap[len(m.heap)-1]
+	m.heap = m.heap[:len(m.heap)-1]
+
+	return x
+}
+
+// page takes the first limit items of the sources and returns the page with the cursor for the next one.
+func (m *pageMerge[T]) page(prev pageCursor, limit int) (MapCallPage[T], pageCursor, error) {
+	for i := range m.sources {
+		if len(m.sources[i].items) > 0 {
+			m.heap = append(m.heap, i)
+		}
+	}
+
+	heap.Init(m)
+
+	page := MapCallPage[T]{Items: make([]T, 0, limit)}
+
+	for len(page.Items) < limit && m.Len() > 0 {
+		source := &m.sources[m.heap[0]]
+		page.Items = append(page.Items, source.items[source.consumed])
+		source.consumed++
+
+		if source.consumed == len(source.items) {
+			heap.Pop(m)
+		} else {
+			heap.Fix(m, 0)
+		}
+	}
+
+	next := pageCursor{
+		After: make(map[string]msgpack.RawMessage, len(m.sources)),
+		Done:  slices.Clone(prev.Done),
+	}
+
+	for name, after := range prev.After {
+		if !slices.Contains(next.Done, name) {
+			next.After[name] = after
+		}
+	}
+
+	for _, source := range m.sources {
+		if source.exhausted && source.consumed == len(source.items) {
+			delete(next.After, source.name)
+			next.Done = append(next.Done, source.name)
+
+			continue
+		}
+
+		if source.consumed == 0 {
+			continue
+		}
+
+		after, err := msgpack.Marshal(source.items[source.consumed-1])
+		if err != nil {
+			return page, next, fmt.Errorf("can't encode the last item of %s: %w", source.name, err)
+		}
+
+		next.After[source.name] = after
+	}
+
+	return page, next, nil
+}
@@ -0,0 +1,126 @@
+package vshard_router // nolint: revive
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+// isPageMapCall returns a matcher of storage_map requests of RouterMapCallRWPage with the position and the limit.
+func isPageMapCall(after interface{}, limit int) interface{} {
+	return mock.MatchedBy(func(req *tarantool.CallRequest) bool {
+		args, ok := serviceCallArgs(req)
+		if !ok || args[0] != "storage_map" {
+			return false
+		}
+
+		fncArgs, _ := args[3].([]interface{})
+
+		return len(fncArgs) == 3 && fmt.Sprint(fncArgs[0]) == fmt.Sprint(after) &&
+			fmt.Sprint(fncArgs[1]) == fmt.Sprint(limit) && fncArgs[2] == "arg"
+	})
+}
+
+func TestRouterMapCallRWPage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newPool := func(t *testing.T, refs int) *mockpool.Pooler {
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{uint64(5)})).Times(refs)
+		mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Times(refs)
+
+		return mPool
+	}
+
+	rs1Pool := newPool(t, 2)
+	rs1Pool.On("Do", isPageMapCall(nil, 3), pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{true, []int{1, 4, 5}})).Once()
+	rs1Pool.On("Do", isPageMapCall(1, 3), pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{true, []int{4, 5}})).Once()
+
+	// rs2 is exhausted on the first page, so it isn't called for the second one
+	rs2Pool := newPool(t, 2)
+	rs2Pool.On("Do", isPageMapCall(nil, 3), pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{true, []int{2, 3}})).Once()
+
+	router := newTestMapRouter(10, map[string]Pooler{"rs1": rs1Pool, "rs2": rs2Pool})
+
+	opts := RouterMapCallRWPageOptions{Limit: 3}
+
+	page, err := RouterMapCallRWPage[int](router, ctx, "select", []interface{}{"arg"}, cmp.Compare[int], opts)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, page.Items)
+	require.NotEmpty(t, page.Cursor)
+
+	opts.Cursor = page.Cursor
+
+	page, err = RouterMapCallRWPage[int](router, ctx, "select", []interface{}{"arg"}, cmp.Compare[int], opts)
+	require.NoError(t, err)
+	require.Equal(t, []int{4, 5}, page.Items)
+	require.Empty(t, page.Cursor)
+}
+
+func TestRouterMapCallRWPage_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	router := newTestMapRouter(10, map[string]Pooler{"rs": mockpool.NewPooler(t)})
+
+	_, err := RouterMapCallRWPage[int](router, ctx, "select", nil, cmp.Compare[int],
+		RouterMapCallRWPageOptions{Limit: 3, Cursor: "not a cursor"})
+	require.ErrorIs(t, err, ErrInvalidPageCursor)
+
+	_, err = RouterMapCallRWPage[int](router, ctx, "select", nil, cmp.Compare[int], RouterMapCallRWPageOptions{})
+	require.Error(t, err)
+
+	mPool := mockpool.NewPooler(t)
+	mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{uint64(10)})).Once()
+	mPool.On("Do", isPageMapCall(nil, 3), pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{nil, StorageCallVShardError{Message: "select failed"}})).Once()
+	mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+		Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+	metrics := &mapCallMetrics{}
+
+	router = newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+	router.cfg.Metrics = metrics
+
+	_, err = RouterMapCallRWPage[int](router, ctx, "select", []interface{}{"arg"}, cmp.Compare[int],
+		RouterMapCallRWPageOptions{Limit: 3})
+	require.ErrorIs(t, err, ErrMapCallMap)
+	require.ErrorContains(t, err, "select failed")
+	require.Equal(t, 1, metrics.failures)
+}
+
+func TestPageMerge(t *testing.T) {
+	t.Parallel()
+
+	merge := &pageMerge[int]{
+		compare: cmp.Compare[int],
+		sources: []pageSource[int]{
+			{name: "b", items: []int{1, 3, 5}},
+			{name: "a", items: []int{1, 2}, exhausted: true},
+			{name: "c"},
+		},
+	}
+
+	page, next, err := merge.page(pageCursor{Done: []string{"d"}}, 4)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 1, 2, 3}, page.Items)
+	require.ElementsMatch(t, []string{"d", "a"}, next.Done)
+	require.Len(t, next.After, 1)
+	require.Equal(t, []byte{3}, []byte(next.After["b"]))
+}
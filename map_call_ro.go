@@ -0,0 +1,293 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// vshardStorageBucketsCount is a storage function that returns the number of buckets stored on the instance.
+const vshardStorageBucketsCount = "vshard.storage.buckets_count"
+
+// ErrMapCallCoverageGap is returned by RouterMapCallRO if the bucket counts of replicasets that have answered
+// don't sum up to Config.TotalBucketCount.
+var ErrMapCallCoverageGap = fmt.Errorf("map-reduce bucket coverage gap")
+
+// RouterMapCallROOptions sets options for RouterMapCallRO.
+type RouterMapCallROOptions struct {
+	// Timeout defines timeout for RouterMapCallRO.
+	Timeout time.Duration
+	// Mode selects the instance of every replicaset as Router.Call does. Only read modes are allowed:
+	// CallModeRO (default), CallModeBRO, CallModeRE and CallModeBRE.
+	Mode CallMode
+	// CheckBucketCount requests the number of buckets from every instance along with the result,
+	// and makes RouterMapCallRO return ErrMapCallCoverageGap if their sum differs from Config.TotalBucketCount.
+	// The number is requested by a separate call, so the check is approximate, see RouterMapCallRO.
+	CheckBucketCount bool
+}
+
+// MapCallROResult is a result of RouterMapCallRO on a replicaset.
+type MapCallROResult[T any] struct {
+	// Value is the first value returned by user function, it is set if Err is nil.
+	Value T
+	// Instance is a name of the instance the function has been called on.
+	// It is empty if there are no connected instances suitable for the mode, or if the pooler can't send
+	// requests to the specific instance (the instance is chosen by the pooler then).
+	Instance string
+	// BucketCount is a number of buckets stored on the instance, it is set only if
+	// RouterMapCallROOptions.CheckBucketCount is true.
+	BucketCount uint64
+	// Err is *ReplicasetMapCallError if the call has failed on the replicaset.
+	Err error
+}
+
+// RouterMapCallRO is a best-effort map-reduce for reads: the function is called on an instance of every replicaset
+// chosen by the mode, without refs. So masters are not involved and rebalancing is not blocked, but the buckets
+// may be moved during the call, and a bucket may be missed or seen twice. Set RouterMapCallROOptions.CheckBucketCount
+// to detect coverage gaps. The check is approximate: bucket counts are requested by a separate call to the same
+// instance, so the buckets moved between the two calls are not detected.
+// The result contains every replicaset, successful or not, the returned error is not nil only if the call can't be
+// performed at all or the coverage check has failed.
+// T is a type of the first value returned by user defined function 'fnc'.
+func RouterMapCallRO[T any](r *Router, ctx context.Context,
+	fnc string, args interface{}, opts RouterMapCallROOptions,
+) (map[string]MapCallROResult[T], error) {
+	if len(r.cfg.Interceptors) == 0 {
+		return routerMapCallRO[T](r, ctx, fnc, args, opts, nil)
+	}
+
+	info := &CallInfo{
+		Mode:      opts.Mode,
+		MapReduce: true,
+		Fnc:       fnc,
+		Args:      args,
+	}
+
+	reply, err := r.intercept(ctx, info, func(ctx context.Context, info *CallInfo) (interface{}, error) {
+		opts := opts
+		opts.Mode = info.Mode
+
+		return routerMapCallRO[T](r, ctx, info.Fnc, info.Args, opts, info)
+	})
+
+	return interceptedReply[map[string]MapCallROResult[T]](reply, err)
+}
+
+// routerMapCallRO implements RouterMapCallRO, info is nil if there are no interceptors.
+func routerMapCallRO[T any](r *Router, ctx context.Context,
+	fnc string, args interface{}, opts RouterMapCallROOptions, info *CallInfo,
+) (map[string]MapCallROResult[T], error) {
+	if opts.Mode == CallModeRW {
+		return nil, fmt.Errorf("RouterMapCallRO doesn't support CallModeRW, use RouterMapCallRW")
+	}
+
+	poolMode, _, err := callModeToPoolMode(opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.beginRequest(); err != nil {
+		return nil, err
+	}
+	defer r.endRequest()
+
+	timeout := callTimeoutDefault
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+
+	timeStart := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	nameToReplicasetRef := r.getNameToReplicaset()
+
+	type roFutures struct {
+		name          string
+		rs            *Replicaset
+		future        *tarantool.Future
+		countFuture   *tarantool.Future
+		releaseLimits func()
+		probe         bool
+	}
+
+	mapReq := tarantool.NewCallRequest(fnc).
+		Context(ctx).
+		Args(args)
+	countReq := tarantool.NewCallRequest(vshardStorageBucketsCount).
+		Context(ctx)
+
+	nameToResult := make(map[string]MapCallROResult[T], len(nameToReplicasetRef))
+	rsFutures := make([]roFutures, 0, len(nameToReplicasetRef))
+
+	names := make([]string, 0, len(nameToReplicasetRef))
+	for name := range nameToReplicasetRef {
+		names = append(names, name)
+	}
+
+	// acquire limits in the same order as acquireAllLimits does to avoid deadlocks between map-reduce requests
+	sort.Strings(names)
+
+	for _, name := range names {
+		rs := nameToReplicasetRef[name]
+
+		rsFail := func(err error) {
+			err = newReplicasetMapCallError(name, MapCallStageMap, err)
+			info.addAttempt(name, err)
+
+			nameToResult[name] = MapCallROResult[T]{Err: err}
+		}
+
+		releaseLimits, err := rs.acquireLimits(ctx, opts.Mode)
+		if err != nil {
+			rsFail(err)
+
+			continue
+		}
+
+		probe, allowed, lastErr := rs.breaker.allow()
+		if !allowed {
+			rs.abortLimits(opts.Mode)
+			rsFail(newVShardErrorReplicasetInBackoff(rs.info, lastErr))
+
+			continue
+		}
+
+		instance, err := rs.mapReadTarget(opts.Mode, poolMode)
+		if err != nil {
+			// there are no healthy replicas, it's not a failure of the replicaset
+			releaseLimits()
+			rs.breaker.done(probe, nil)
+			rsFail(err)
+
+			continue
+		}
+
+		rsFuture := roFutures{
+			name:          name,
+			rs:            rs,
+			future:        rs.doInstance(mapReq, instance, poolMode),
+			releaseLimits: releaseLimits,
+			probe:         probe,
+		}
+
+		if opts.CheckBucketCount {
+			rsFuture.countFuture = rs.doInstance(countReq, instance, poolMode)
+		}
+
+		nameToResult[name] = MapCallROResult[T]{Instance: instance}
+		rsFutures = append(rsFutures, rsFuture)
+	}
+
+	var bucketCount uint64
+
+	for _, rsFuture := range rsFutures {
+		result := nameToResult[rsFuture.name]
+
+		var mapResponse callFirstValueProto[T]
+
+		err := getTyped(ctx, rsFuture.future, &mapResponse)
+		if err == nil && rsFuture.countFuture != nil {
+			var countResponse struct {
+				_msgpack struct{} `msgpack:",as_array"` //nolint:unused
+				Count    uint64
+			}
+
+			err = getTyped(ctx, rsFuture.countFuture, &countResponse)
+			result.BucketCount = countResponse.Count
+		}
+
+		rsFuture.releaseLimits()
+
+		if err != nil && (isTransportError(err) || errors.Is(ctx.Err(), context.DeadlineExceeded)) {
+			rsFuture.rs.breaker.done(rsFuture.probe, err)
+		} else {
+			rsFuture.rs.breaker.done(rsFuture.probe, nil)
+		}
+
+		if err != nil {
+			result.BucketCount = 0
+			result.Err = newReplicasetMapCallError(rsFuture.name, MapCallStageMap, err)
+			info.addAttempt(rsFuture.name, result.Err)
+		} else {
+			result.Value = mapResponse.value
+			bucketCount += result.BucketCount
+			info.addAttempt(rsFuture.name, nil)
+		}
+
+		nameToResult[rsFuture.name] = result
+	}
+
+	if opts.CheckBucketCount && bucketCount != r.cfg.TotalBucketCount {
+		err = fmt.Errorf("%w: total bucket count got %d, expected %d",
+			ErrMapCallCoverageGap, bucketCount, r.cfg.TotalBucketCount)
+	}
+
+	r.metrics().RequestDuration(time.Since(timeStart), fnc, err == nil, true)
+
+	return nameToResult, err
+}
+
+// mapReadTarget chooses an instance for a read map-reduce call as Router.Call does for the mode,
+// but always returns a certain instance if there is one, so it's known who has answered.
+// It returns pool.ErrNoRoInstance for CallModeRO if there are no connected healthy replicas.
+func (rs *Replicaset) mapReadTarget(mode CallMode, poolMode pool.Mode) (string, error) {
+	if !rs.canDoInstance() {
+		return "", nil
+	}
+
+	instance, err := rs.readTarget(poolMode)
+	if instance != "" || err != nil && mode != CallModeRE {
+		return instance, err
+	}
+
+	// the instances are shuffled, so the load is spread among the instances of the nearest zone
+	instances := rs.orderedInstances(poolMode)
+	if len(instances) == 0 && mode == CallModeRE {
+		// fall back to the master as Router.Call does
+		instances = rs.orderedInstances(pool.PreferRO)
+	}
+
+	if len(instances) == 0 {
+		if poolMode == pool.RO && mode != CallModeRE {
+			return "", pool.ErrNoRoInstance
+		}
+
+		return "", nil
+	}
+
+	return instances[0], nil
+}
+
+// callFirstValueProto decodes the first value returned by user defined function, the other values are skipped.
+type callFirstValueProto[T any] struct {
+	value T
+}
+
+func (r *callFirstValueProto[T]) DecodeMsgpack(d *msgpack.Decoder) error {
+	respArrayLen, err := d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < respArrayLen; i++ {
+		if i == 0 {
+			err = d.Decode(&r.value)
+		} else {
+			err = d.Skip()
+		}
+
+		if err != nil {
+			return fmt.Errorf("can't decode value %T: %w", r.value, err)
+		}
+	}
+
+	return nil
+}
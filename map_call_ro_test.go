@@ -0,0 +1,176 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+func TestRouterMapCallRO(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	instances := map[string]pool.ConnectionInfo{
+		"master":  {ConnectedNow: true, ConnRole: pool.MasterRole},
+		"replica": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+	}
+
+	newReplicaPool := func(t *testing.T, value string, bucketCount uint64) instancePoolerMock {
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(instances)
+		mPool.On("DoInstance", isCallOf("echo"), "replica").
+			Return(newCallResponseFuture(t, []interface{}{value, "skipped"})).Once()
+		mPool.On("DoInstance", isCallOf(vshardStorageBucketsCount), "replica").
+			Return(newCallResponseFuture(t, []interface{}{bucketCount})).Once()
+
+		return mPool
+	}
+
+	t.Run("replicas answer", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestMapRouter(10, map[string]Pooler{
+			"rs1": newReplicaPool(t, "rs1", 4),
+			"rs2": newReplicaPool(t, "rs2", 6),
+		})
+
+		results, err := RouterMapCallRO[string](router, ctx, "echo", []interface{}{},
+			RouterMapCallROOptions{CheckBucketCount: true})
+		require.NoError(t, err)
+		require.Equal(t, map[string]MapCallROResult[string]{
+			"rs1": {Value: "rs1", Instance: "replica", BucketCount: 4},
+			"rs2": {Value: "rs2", Instance: "replica", BucketCount: 6},
+		}, results)
+	})
+
+	t.Run("coverage gap", func(t *testing.T) {
+		t.Parallel()
+
+		failedPool := newInstancePoolerMock(t)
+		failedPool.On("GetInfo").Return(instances)
+		failedPool.On("DoInstance", isCallOf("echo"), "replica").
+			Return(newErrorFuture(tarantool.ClientError{Code: tarantool.ErrConnectionClosed})).Once()
+		failedPool.On("DoInstance", isCallOf(vshardStorageBucketsCount), "replica").
+			Return(newCallResponseFuture(t, []interface{}{uint64(6)})).Once()
+
+		router := newTestMapRouter(10, map[string]Pooler{
+			"rs1":    newReplicaPool(t, "rs1", 4),
+			"failed": failedPool,
+		})
+
+		results, err := RouterMapCallRO[string](router, ctx, "echo", []interface{}{},
+			RouterMapCallROOptions{CheckBucketCount: true})
+		require.ErrorIs(t, err, ErrMapCallCoverageGap)
+		require.NoError(t, results["rs1"].Err)
+		require.ErrorIs(t, results["failed"].Err, ErrMapCallMap)
+		require.Equal(t, "replica", results["failed"].Instance)
+		require.Zero(t, results["failed"].BucketCount)
+	})
+
+	t.Run("master fallback", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{
+			"master":  {ConnectedNow: true, ConnRole: pool.MasterRole},
+			"replica": {ConnectedNow: false, ConnRole: pool.ReplicaRole},
+		})
+		mPool.On("DoInstance", isCallOf("echo"), "master").
+			Return(newCallResponseFuture(t, []interface{}{"ok"})).Once()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+		results, err := RouterMapCallRO[string](router, ctx, "echo", []interface{}{},
+			RouterMapCallROOptions{Mode: CallModeRE})
+		require.NoError(t, err)
+		require.Equal(t, MapCallROResult[string]{Value: "ok", Instance: "master"}, results["rs"])
+	})
+
+	t.Run("no replicas", func(t *testing.T) {
+		t.Parallel()
+
+		// no requests are expected
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{
+			"master":  {ConnectedNow: true, ConnRole: pool.MasterRole},
+			"replica": {ConnectedNow: false, ConnRole: pool.ReplicaRole},
+		})
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+		results, err := RouterMapCallRO[string](router, ctx, "echo", []interface{}{}, RouterMapCallROOptions{})
+		require.NoError(t, err)
+		require.ErrorIs(t, results["rs"].Err, pool.ErrNoRoInstance)
+		require.ErrorIs(t, results["rs"].Err, ErrMapCallMap)
+	})
+
+	t.Run("circuit breaker", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(instances)
+		mPool.On("DoInstance", isCallOf("echo"), "replica").
+			Return(newErrorFuture(tarantool.ClientError{Code: tarantool.ErrConnectionClosed})).Once()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+		rs := router.getNameToReplicaset()["rs"]
+		rs.breaker = newCircuitBreaker(CircuitBreakerOpts{FailureThreshold: 1, OpenTimeout: time.Minute}, nil)
+
+		results, err := RouterMapCallRO[string](router, ctx, "echo", []interface{}{}, RouterMapCallROOptions{})
+		require.NoError(t, err)
+		require.Error(t, results["rs"].Err)
+		require.Equal(t, CircuitBreakerOpen, rs.CircuitBreakerState())
+
+		// the request is not sent to the replicaset
+		results, err = RouterMapCallRO[string](router, ctx, "echo", []interface{}{}, RouterMapCallROOptions{})
+		require.NoError(t, err)
+
+		var vshardError *StorageCallVShardError
+		require.ErrorAs(t, results["rs"].Err, &vshardError)
+		require.Equal(t, VShardErrNameReplicasetInBackoff, vshardError.Name)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestMapRouter(10, map[string]Pooler{
+			"rs1": newReplicaPool(t, "rs1", 4),
+			"rs2": newInstancePoolerMock(t),
+		})
+		router.cfg.Limits = &LimitsOpts{Modes: map[CallMode]LimitOpts{CallModeRO: {MaxInFlight: 1}}}
+
+		for name, rs := range router.getNameToReplicaset() {
+			rs.limiters = router.newReplicasetLimiters(name)
+		}
+
+		// the only in-flight slot of rs2 is taken, so no request is sent to rs2
+		release, err := router.getNameToReplicaset()["rs2"].acquireLimits(ctx, CallModeRO)
+		require.NoError(t, err)
+		defer release()
+
+		results, err := RouterMapCallRO[string](router, ctx, "echo", []interface{}{},
+			RouterMapCallROOptions{CheckBucketCount: true})
+		require.ErrorIs(t, err, ErrMapCallCoverageGap)
+		require.Equal(t, MapCallROResult[string]{Value: "rs1", Instance: "replica", BucketCount: 4}, results["rs1"])
+		require.ErrorIs(t, results["rs2"].Err, ErrLimitExceeded)
+		require.ErrorIs(t, results["rs2"].Err, ErrMapCallMap)
+	})
+
+	t.Run("RW mode", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mockpool.NewPooler(t)})
+
+		_, err := RouterMapCallRO[string](router, ctx, "echo", []interface{}{},
+			RouterMapCallROOptions{Mode: CallModeRW})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,666 @@
+package vshard_router // nolint: revive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vmihailenco/msgpack/v5"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+// newTestMapRouter creates a router with a replicaset for each pool.
+func newTestMapRouter(totalBucketCount uint64, pools map[string]Pooler) *Router {
+	router := &Router{
+		cfg: Config{
+			TotalBucketCount: totalBucketCount,
+			Loggerf:          emptyLogfProvider,
+			Metrics:          emptyMetricsProvider,
+		},
+	}
+	router.setEmptyRouteMap()
+
+	nameToReplicaset := make(map[string]*Replicaset, len(pools))
+	for name, conn := range pools {
+		nameToReplicaset[name] = newReplicaset(ReplicasetInfo{Name: name}, conn, nil)
+	}
+
+	_ = router.swapNameToReplicaset(nil, &nameToReplicaset)
+
+	return router
+}
+
+// callRequestBody decodes the function name and the arguments of the call request.
+func callRequestBody(req *tarantool.CallRequest) (fnc string, args []interface{}, ok bool) {
+	var buf bytes.Buffer
+
+	if err := req.Body(nil, msgpack.NewEncoder(&buf)); err != nil {
+		return "", nil, false
+	}
+
+	const (
+		iprotoFunctionName = 0x22
+		iprotoTuple        = 0x21
+	)
+
+	var body map[int]interface{}
+	if err := msgpack.Unmarshal(buf.Bytes(), &body); err != nil {
+		return "", nil, false
+	}
+
+	fnc, _ = body[iprotoFunctionName].(string)
+	args, _ = body[iprotoTuple].([]interface{})
+
+	return fnc, args, true
+}
+
+// serviceCallArgs decodes the arguments of vshard.storage._call request, ok is false for other requests.
+func serviceCallArgs(req *tarantool.CallRequest) (args []interface{}, ok bool) {
+	fnc, args, ok := callRequestBody(req)
+
+	return args, ok && fnc == vshardStorageServiceCall && len(args) > 0
+}
+
+// isCallOf returns a matcher of call requests of the function.
+func isCallOf(fnc string) interface{} {
+	return mock.MatchedBy(func(req *tarantool.CallRequest) bool {
+		reqFnc, _, ok := callRequestBody(req)
+
+		return ok && reqFnc == fnc
+	})
+}
+
+// isServiceCall returns a matcher of vshard.storage._call requests for the storage function.
+func isServiceCall(storageFnc string) interface{} {
+	return mock.MatchedBy(func(req *tarantool.CallRequest) bool {
+		args, ok := serviceCallArgs(req)
+
+		return ok && args[0] == storageFnc
+	})
+}
+
+// isServiceCallWithBuckets returns a matcher of vshard.storage._call requests for the storage function
+// with the bucket identifiers at the given position of the arguments.
+func isServiceCallWithBuckets(storageFnc string, pos func(args []interface{}) interface{}, buckets ...uint64) interface{} {
+	return mock.MatchedBy(func(req *tarantool.CallRequest) bool {
+		args, ok := serviceCallArgs(req)
+		if !ok || args[0] != storageFnc {
+			return false
+		}
+
+		got, _ := pos(args).([]interface{})
+		if len(got) != len(buckets) {
+			return false
+		}
+
+		for i, bucketID := range buckets {
+			if fmt.Sprint(got[i]) != fmt.Sprint(bucketID) {
+				return false
+			}
+		}
+
+		return true
+	})
+}
+
+func TestRouterMapCallRWPartial(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("partial results", func(t *testing.T) {
+		t.Parallel()
+
+		okPool := mockpool.NewPooler(t)
+		okPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{uint64(5)})).Once()
+		okPool.On("Do", isServiceCall("storage_map"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+		okPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		refFailedPool := mockpool.NewPooler(t)
+		refFailedPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, "STORAGE_REF_ADD"})).Once()
+		refFailedPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		mapFailedPool := mockpool.NewPooler(t)
+		mapFailedPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{uint64(5)})).Once()
+		mapFailedPool.On("Do", isServiceCall("storage_map"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, StorageCallVShardError{Name: "STORAGE_REF_USE"}})).Once()
+		mapFailedPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		router := newTestMapRouter(15, map[string]Pooler{
+			"ok":         okPool,
+			"ref_failed": refFailedPool,
+			"map_failed": mapFailedPool,
+		})
+
+		results, err := RouterMapCallRWPartial[string](router, ctx, "echo", []interface{}{"ok"},
+			RouterMapCallRWPartialOptions{MinSuccess: 1})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		require.Equal(t, MapCallResult[string]{Value: "ok", BucketCount: 5}, results["ok"])
+
+		require.ErrorIs(t, results["ref_failed"].Err, ErrMapCallRef)
+		require.NotErrorIs(t, results["ref_failed"].Err, ErrMapCallTimeout)
+
+		var mapCallErr *ReplicasetMapCallError
+		require.ErrorAs(t, results["map_failed"].Err, &mapCallErr)
+		require.Equal(t, "map_failed", mapCallErr.Replicaset)
+		require.Equal(t, MapCallStageMap, mapCallErr.Stage)
+		require.Equal(t, uint64(5), results["map_failed"].BucketCount)
+
+		var vshardErr StorageCallVShardError
+		require.ErrorAs(t, results["map_failed"].Err, &vshardErr)
+		require.Equal(t, "STORAGE_REF_USE", vshardErr.Name)
+	})
+
+	t.Run("not enough results", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newErrorFuture(tarantool.ClientError{Code: tarantool.ErrTimeouted})).Once()
+		mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+		results, err := RouterMapCallRWPartial[string](router, ctx, "echo", []interface{}{"ok"},
+			RouterMapCallRWPartialOptions{MinSuccess: 1})
+		require.ErrorIs(t, err, ErrMapCallNotEnoughResults)
+		require.ErrorIs(t, results["rs"].Err, ErrMapCallRef)
+	})
+
+	t.Run("ref retry", func(t *testing.T) {
+		t.Parallel()
+
+		refAddErr := map[string]interface{}{"name": VShardErrNameStorageRefAdd, "code": 37}
+
+		retriedPool := mockpool.NewPooler(t)
+		retriedPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, refAddErr})).Once()
+		retriedPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{uint64(5)})).Once()
+		retriedPool.On("Do", isServiceCall("storage_map"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+		retriedPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		// the replicaset that has been referenced at the first attempt is not referenced again
+		okPool := mockpool.NewPooler(t)
+		okPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{uint64(5)})).Once()
+		okPool.On("Do", isServiceCall("storage_map"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+		okPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		metrics := &mapCallMetrics{}
+
+		router := newTestMapRouter(10, map[string]Pooler{"retried": retriedPool, "ok": okPool})
+		router.cfg.Metrics = metrics
+
+		results, err := RouterMapCallRWPartial[string](router, ctx, "echo", nil, RouterMapCallRWPartialOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]MapCallResult[string]{
+			"retried": {Value: "ok", BucketCount: 5},
+			"ok":      {Value: "ok", BucketCount: 5},
+		}, results)
+		require.Equal(t, map[string]int{"storage_ref_add": 1}, metrics.retries)
+	})
+
+	t.Run("ref retry timeout", func(t *testing.T) {
+		t.Parallel()
+
+		refAddErr := map[string]interface{}{"name": VShardErrNameStorageRefAdd, "code": 37}
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, refAddErr}))
+		mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+		results, err := RouterMapCallRWPartial[string](router, ctx, "echo", nil,
+			RouterMapCallRWPartialOptions{Timeout: 3 * storageRefRetryPause})
+		require.NoError(t, err)
+		require.ErrorIs(t, results["rs"].Err, ErrMapCallRef)
+		require.ErrorIs(t, results["rs"].Err, ErrMapCallTimeout)
+	})
+}
+
+func TestReplicasetMapCallError(t *testing.T) {
+	t.Parallel()
+
+	err := newReplicasetMapCallError("rs", MapCallStageMap, context.DeadlineExceeded)
+	require.True(t, err.Timeout)
+	require.ErrorIs(t, err, ErrMapCallMap)
+	require.ErrorIs(t, err, ErrMapCallTimeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotErrorIs(t, err, ErrMapCallRef)
+	require.Equal(t, "replicaset rs: storage_map failed: context deadline exceeded", err.Error())
+
+	// the map-reduce timeout doesn't make an error of a replicaset that has failed before it a timeout
+	err = newReplicasetMapCallError("rs", MapCallStageRef, fmt.Errorf("connection lost"))
+	require.False(t, err.Timeout)
+	require.ErrorIs(t, err, ErrMapCallRef)
+	require.NotErrorIs(t, err, ErrMapCallTimeout)
+}
+
+func TestRouterMapPartCallRW(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	refBuckets := func(args []interface{}) interface{} { return args[len(args)-1] }
+	mapBuckets := func(args []interface{}) interface{} { return args[3].([]interface{})[0] }
+
+	refResult := func(moved ...map[string]interface{}) []interface{} {
+		return []interface{}{map[string]interface{}{"rid": 1, "moved": moved}}
+	}
+
+	t.Run("only owners are called", func(t *testing.T) {
+		t.Parallel()
+
+		rs1Pool := mockpool.NewPooler(t)
+		rs1Pool.On("Do", isServiceCallWithBuckets("storage_ref_make_with_buckets", refBuckets, 1, 2), pool.RW).
+			Return(newCallResponseFuture(t, refResult())).Once()
+		rs1Pool.On("Do", isServiceCallWithBuckets("storage_map", mapBuckets, 1, 2), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "rs1"})).Once()
+		rs1Pool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		rs2Pool := mockpool.NewPooler(t)
+		rs2Pool.On("Do", isServiceCallWithBuckets("storage_ref_make_with_buckets", refBuckets, 3), pool.RW).
+			Return(newCallResponseFuture(t, refResult())).Once()
+		rs2Pool.On("Do", isServiceCallWithBuckets("storage_map", mapBuckets, 3), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "rs2"})).Once()
+		rs2Pool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		// rs3 owns no requested buckets, so it is not called at all
+		rs3Pool := mockpool.NewPooler(t)
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs1": rs1Pool, "rs2": rs2Pool, "rs3": rs3Pool})
+		_, _ = router.BucketSet(1, "rs1")
+		_, _ = router.BucketSet(2, "rs1")
+		_, _ = router.BucketSet(3, "rs2")
+		_, _ = router.BucketSet(4, "rs3")
+
+		results, err := RouterMapPartCallRW[string](router, ctx, []uint64{2, 3, 1, 2}, "echo", []interface{}{"ok"},
+			RouterMapCallRWOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"rs1": "rs1", "rs2": "rs2"}, results)
+	})
+
+	t.Run("moved bucket", func(t *testing.T) {
+		t.Parallel()
+
+		rs1Pool := mockpool.NewPooler(t)
+		rs1Pool.On("Do", isServiceCallWithBuckets("storage_ref_make_with_buckets", refBuckets, 1, 2), pool.RW).
+			Return(newCallResponseFuture(t, refResult(map[string]interface{}{"id": 2, "dst": "rs2"}))).Once()
+		rs1Pool.On("Do", isServiceCallWithBuckets("storage_map", mapBuckets, 1), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "rs1"})).Once()
+		rs1Pool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		rs2Pool := mockpool.NewPooler(t)
+		rs2Pool.On("Do", isServiceCallWithBuckets("storage_ref_make_with_buckets", refBuckets, 2), pool.RW).
+			Return(newCallResponseFuture(t, refResult())).Once()
+		rs2Pool.On("Do", isServiceCallWithBuckets("storage_map", mapBuckets, 2), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "rs2"})).Once()
+		rs2Pool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs1": rs1Pool, "rs2": rs2Pool})
+		_, _ = router.BucketSet(1, "rs1")
+		_, _ = router.BucketSet(2, "rs1")
+
+		results, err := RouterMapPartCallRW[string](router, ctx, []uint64{1, 2}, "echo", nil, RouterMapCallRWOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"rs1": "rs1", "rs2": "rs2"}, results)
+
+		rs, err := router.Route(ctx, 2)
+		require.NoError(t, err)
+		require.Equal(t, "rs2", rs.info.Name)
+	})
+
+	t.Run("limits are acquired before ref", func(t *testing.T) {
+		t.Parallel()
+
+		// no requests are expected
+		router := newTestMapRouter(10, map[string]Pooler{"rs1": mockpool.NewPooler(t)})
+		router.cfg.Limits = &LimitsOpts{
+			Modes: map[CallMode]LimitOpts{
+				CallModeRW: {MaxInFlight: 1},
+			},
+		}
+
+		rs := router.getNameToReplicaset()["rs1"]
+		rs.limiters = router.newReplicasetLimiters("rs1")
+
+		release, err := rs.acquireLimits(ctx, CallModeRW)
+		require.NoError(t, err)
+		defer release()
+
+		_, _ = router.BucketSet(1, "rs1")
+
+		_, err = RouterMapPartCallRW[string](router, ctx, []uint64{1}, "echo", nil, RouterMapCallRWOptions{})
+		require.ErrorIs(t, err, ErrLimitExceeded)
+	})
+
+	t.Run("ref error", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", isServiceCall("storage_ref_make_with_buckets"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, "STORAGE_REF_ADD"})).Once()
+		mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+		_, _ = router.BucketSet(1, "rs")
+
+		_, err := RouterMapPartCallRW[string](router, ctx, []uint64{1}, "echo", nil, RouterMapCallRWOptions{})
+		require.ErrorIs(t, err, ErrMapCallRef)
+	})
+
+	t.Run("bucket out of range", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mockpool.NewPooler(t)})
+
+		_, err := RouterMapPartCallRW[string](router, ctx, []uint64{11}, "echo", nil, RouterMapCallRWOptions{})
+		require.Error(t, err)
+	})
+}
+
+func TestRouterMapCallRWReduce(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newPool := func(t *testing.T, mapFuture *tarantool.Future) *mockpool.Pooler {
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{uint64(5)})).Once()
+		mPool.On("Do", isServiceCall("storage_map"), pool.RW).
+			Return(mapFuture).Once()
+		mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+		return mPool
+	}
+
+	t.Run("results in order of responses", func(t *testing.T) {
+		t.Parallel()
+
+		slowFuture := tarantool.NewFuture(tarantool.NewCallRequest(vshardStorageServiceCall))
+
+		router := newTestMapRouter(10, map[string]Pooler{
+			"slow": newPool(t, slowFuture),
+			"fast": newPool(t, newCallResponseFuture(t, []interface{}{true, 2})),
+		})
+
+		var names []string
+		var sum int
+
+		err := RouterMapCallRWReduce[int](router, ctx, "echo", nil, RouterMapCallRWOptions{},
+			func(rsName string, value int) bool {
+				if rsName == "fast" {
+					// the slow replicaset responds only after the fast one is reduced
+					setCallResponse(t, slowFuture, []interface{}{true, 1})
+				}
+
+				names = append(names, rsName)
+				sum += value
+
+				return true
+			})
+		require.NoError(t, err)
+		require.Equal(t, []string{"fast", "slow"}, names)
+		require.Equal(t, 3, sum)
+	})
+
+	t.Run("stop early", func(t *testing.T) {
+		t.Parallel()
+
+		router := newTestMapRouter(10, map[string]Pooler{
+			"rs1": newPool(t, newCallResponseFuture(t, []interface{}{true, 1})),
+			"rs2": newPool(t, newCallResponseFuture(t, []interface{}{true, 1})),
+		})
+
+		var calls int
+
+		err := RouterMapCallRWReduce[int](router, ctx, "echo", nil, RouterMapCallRWOptions{},
+			func(string, int) bool {
+				calls++
+
+				return false
+			})
+		require.NoError(t, err)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("map error", func(t *testing.T) {
+		t.Parallel()
+
+		metrics := &mapCallMetrics{}
+
+		router := newTestMapRouter(5, map[string]Pooler{
+			"rs": newPool(t, newCallResponseFuture(t, []interface{}{nil, StorageCallVShardError{Name: "STORAGE_REF_USE"}})),
+		})
+		router.cfg.Metrics = metrics
+
+		err := RouterMapCallRWReduce[int](router, ctx, "echo", nil, RouterMapCallRWOptions{},
+			func(string, int) bool {
+				t.Fatal("reduce must not be called")
+
+				return true
+			})
+		require.ErrorContains(t, err, "STORAGE_REF_USE")
+		require.ErrorIs(t, err, ErrMapCallMap)
+
+		var rsErr *ReplicasetMapCallError
+		require.ErrorAs(t, err, &rsErr)
+		require.Equal(t, "rs", rsErr.Replicaset)
+		require.Equal(t, 1, metrics.failures)
+	})
+}
+
+// mapCallMetrics counts RetryOnCall, StorageUnrefError and failed RequestDuration calls.
+type mapCallMetrics struct {
+	EmptyMetrics
+
+	mutex       sync.Mutex
+	retries     map[string]int
+	unrefErrors map[string]int
+	failures    int
+}
+
+func (m *mapCallMetrics) RequestDuration(_ time.Duration, _ string, ok, _ bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !ok {
+		m.failures++
+	}
+}
+
+func (m *mapCallMetrics) RetryOnCall(reason string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.retries == nil {
+		m.retries = make(map[string]int)
+	}
+
+	m.retries[reason]++
+}
+
+func (m *mapCallMetrics) StorageUnrefError(replicaset string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.unrefErrors == nil {
+		m.unrefErrors = make(map[string]int)
+	}
+
+	m.unrefErrors[replicaset]++
+}
+
+func TestRouter_storageUnrefAll(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	failedPool := mockpool.NewPooler(t)
+	failedPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+		Return(newCallResponseFunc(t, []interface{}{nil, "unref failed"})).Once()
+
+	okPool := mockpool.NewPooler(t)
+	okPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+		Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+	metrics := &mapCallMetrics{}
+
+	router := newTestMapRouter(10, map[string]Pooler{"failed": failedPool, "ok": okPool})
+	router.cfg.Metrics = metrics
+
+	nameToReplicaset := copyMap(router.getNameToReplicaset())
+
+	// the removed replicaset is skipped, its connections are closed
+	nameToReplicaset["removed"] = newReplicaset(ReplicasetInfo{Name: "removed"}, mockpool.NewPooler(t), nil)
+
+	router.storageUnrefAll(ctx, nameToReplicaset, 1)
+
+	// the responses are checked in background
+	require.Eventually(t, func() bool {
+		metrics.mutex.Lock()
+		defer metrics.mutex.Unlock()
+
+		return metrics.unrefErrors["failed"] == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestRouterMapCallRW_Cancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mPool := mockpool.NewPooler(t)
+	mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{uint64(10)})).Once()
+	// the map request hangs until the call is canceled
+	mPool.On("Do", isServiceCall("storage_map"), pool.RW).
+		Return(tarantool.NewFuture(tarantool.NewCallRequest(vshardStorageServiceCall))).Once()
+	mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+		Return(newCallResponseFunc(t, []interface{}{true})).Once()
+
+	router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err := RouterMapCallRW[string](router, ctx, "echo", nil, RouterMapCallRWOptions{Timeout: time.Minute})
+	require.ErrorContains(t, err, context.Canceled.Error())
+}
+
+func TestRouterMapCallRW_RefRetry(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newPool := func(t *testing.T, refResponses ...interface{}) *mockpool.Pooler {
+		mPool := mockpool.NewPooler(t)
+
+		for _, refResponse := range refResponses {
+			mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+				Return(newCallResponseFuture(t, refResponse)).Once()
+		}
+
+		mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true})).Times(len(refResponses))
+
+		return mPool
+	}
+
+	t.Run("bucket count mismatch", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newPool(t, []interface{}{uint64(9)}, []interface{}{uint64(10)})
+		mPool.On("Do", isServiceCall("storage_map"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		metrics := &mapCallMetrics{}
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+		router.cfg.Metrics = metrics
+
+		results, err := RouterMapCallRW[string](router, ctx, "echo", nil, RouterMapCallRWOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"rs": "ok"}, results)
+		require.Equal(t, map[string]int{"bucket_count_mismatch": 1}, metrics.retries)
+	})
+
+	t.Run("storage is referenced", func(t *testing.T) {
+		t.Parallel()
+
+		refAddErr := map[string]interface{}{"name": VShardErrNameStorageRefAdd, "code": 37}
+
+		mPool := newPool(t, []interface{}{nil, refAddErr}, []interface{}{uint64(10)})
+		mPool.On("Do", isServiceCall("storage_map"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		metrics := &mapCallMetrics{}
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+		router.cfg.Metrics = metrics
+
+		_, err := RouterMapCallRW[string](router, ctx, "echo", nil, RouterMapCallRWOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"storage_ref_add": 1}, metrics.retries)
+	})
+
+	t.Run("not retryable error", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newPool(t, []interface{}{nil, "unexpected"})
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+		_, err := RouterMapCallRW[string](router, ctx, "echo", nil, RouterMapCallRWOptions{})
+		require.ErrorContains(t, err, "unexpected")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", isServiceCall("storage_ref"), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{uint64(9)}))
+		mPool.On("Do", isServiceCall("storage_unref"), pool.RW).
+			Return(newCallResponseFunc(t, []interface{}{true}))
+
+		router := newTestMapRouter(10, map[string]Pooler{"rs": mPool})
+
+		_, err := RouterMapCallRW[string](router, ctx, "echo", nil,
+			RouterMapCallRWOptions{Timeout: 3 * storageRefRetryPause})
+		require.ErrorContains(t, err, "total bucket count got 9, expected 10")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
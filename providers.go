@@ -10,6 +10,12 @@ var (
 	emptyMetricsProvider MetricsProvider = (*EmptyMetrics)(nil)
 	emptyLogfProvider    LogfProvider    = emptyLogger{}
 
+	// Ensure EmptyMetrics implements optional metrics interfaces
+	_ CircuitBreakerMetricsProvider = (*EmptyMetrics)(nil)
+	_ LimiterMetricsProvider        = (*EmptyMetrics)(nil)
+	_ BalancerMetricsProvider       = (*EmptyMetrics)(nil)
+	_ StorageUnrefMetricsProvider   = (*EmptyMetrics)(nil)
+
 	// Ensure StdoutLoggerf implements LogfProvider
 	_ LogfProvider = StdoutLoggerf{}
 	// Ensure SlogLoggerf implements LogfProvider
@@ -94,13 +100,46 @@ type MetricsProvider interface {
 	RequestDuration(duration time.Duration, procedure string, ok, mapReduce bool)
 }
 
+// CircuitBreakerMetricsProvider is an optional interface of MetricsProvider,
+// the state changes of Config.CircuitBreaker are reported if it is implemented.
+type CircuitBreakerMetricsProvider interface {
+	CircuitBreakerStateChange(replicaset string, state CircuitBreakerState)
+}
+
+// LimiterMetricsProvider is an optional interface of MetricsProvider,
+// the queue depths of Config.Limits are reported if it is implemented.
+type LimiterMetricsProvider interface {
+	// LimiterQueueDepth reports the number of requests waiting for Config.Limits of replicaset,
+	// mode is empty for LimitsOpts.Replicaset limits.
+	LimiterQueueDepth(replicaset, mode string, depth int)
+}
+
+// BalancerMetricsProvider is an optional interface of MetricsProvider,
+// the statistics of Config.Balancer are reported if it is implemented.
+type BalancerMetricsProvider interface {
+	// InstanceScore reports the average response time, error rate and score of an instance for Config.Balancer.
+	InstanceScore(replicaset, instance string, latency time.Duration, errorRate, score float64)
+}
+
+// StorageUnrefMetricsProvider is an optional interface of MetricsProvider,
+// failed storage_unref calls of map-reduce are reported if it is implemented.
+type StorageUnrefMetricsProvider interface {
+	// StorageUnrefError reports a failed storage_unref of map-reduce call, the ref stays on the replicaset until
+	// its timeout and blocks rebalancing.
+	StorageUnrefError(replicaset string)
+}
+
 // EmptyMetrics is default empty metrics provider
 // you can embed this type and realize just some metrics
 type EmptyMetrics struct{}
 
-func (e *EmptyMetrics) CronDiscoveryEvent(_ bool, _ time.Duration, _ string) {}
-func (e *EmptyMetrics) RetryOnCall(_ string)                                 {}
-func (e *EmptyMetrics) RequestDuration(_ time.Duration, _ string, _, _ bool) {}
+func (e *EmptyMetrics) CronDiscoveryEvent(_ bool, _ time.Duration, _ string)      {}
+func (e *EmptyMetrics) RetryOnCall(_ string)                                      {}
+func (e *EmptyMetrics) RequestDuration(_ time.Duration, _ string, _, _ bool)      {}
+func (e *EmptyMetrics) CircuitBreakerStateChange(_ string, _ CircuitBreakerState) {}
+func (e *EmptyMetrics) LimiterQueueDepth(_, _ string, _ int)                      {}
+func (e *EmptyMetrics) InstanceScore(_, _ string, _ time.Duration, _, _ float64)  {}
+func (e *EmptyMetrics) StorageUnrefError(_ string)                                {}
 
 // TopologyProvider is external module that can lookup current topology of cluster
 // it might be etcd/config/consul or smth else
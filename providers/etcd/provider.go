@@ -131,6 +131,8 @@ func (p *Provider) GetTopology() (map[vshardrouter.ReplicasetInfo][]vshardrouter
 					switch filepath.Base(instanceInfoNode.Key) {
 					case "cluster":
 						instances[instanceInfoNode.Value] = append(instances[instanceInfoNode.Value], instance)
+					case "zone":
+						instance.Zone = instanceInfoNode.Value
 					case "box":
 						for _, boxNode := range instanceInfoNode.Nodes {
 							switch filepath.Base(boxNode.Key) {
@@ -133,6 +133,7 @@ func TestProvider_GetTopology(t *testing.T) {
 		    instances:
 		      userdb_001:
 		        cluster: userdb
+		        zone: dc1
 		        box:
 		          instance_uuid: 045e12d8-0000-0001-0000-000000000000
 		          listen: 10.0.1.11:3301
@@ -165,10 +166,16 @@ func TestProvider_GetTopology(t *testing.T) {
 
 		_, _ = kapi.Set(ctx, fmt.Sprintf("%s/%s/%s/%s", dbName, "instances", "userdb_001", "box"), "", &client.SetOptions{Dir: true})
 		_, _ = kapi.Set(ctx, fmt.Sprintf("%s/%s/%s/%s/%s", dbName, "instances", "userdb_001", "box", "listen"), "10.0.1.13:3303", &client.SetOptions{Dir: false})
+		_, _ = kapi.Set(ctx, fmt.Sprintf("%s/%s/%s/%s", dbName, "instances", "userdb_001", "zone"), "dc1", &client.SetOptions{Dir: false})
 
 		topology, err := p.GetTopology()
 		require.NoError(t, err)
 		require.NotNil(t, topology)
+
+		for _, instances := range topology {
+			require.Len(t, instances, 1)
+			require.Equal(t, "dc1", instances[0].Zone)
+		}
 	})
 
 	t.Run("mapCluster2Instances", func(t *testing.T) {
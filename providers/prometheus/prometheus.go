@@ -11,6 +11,14 @@ import (
 // Check that provider implements MetricsProvider interface
 var _ vshardrouter.MetricsProvider = (*Provider)(nil)
 
+// Check that provider implements optional metrics interfaces
+var (
+	_ vshardrouter.CircuitBreakerMetricsProvider = (*Provider)(nil)
+	_ vshardrouter.LimiterMetricsProvider        = (*Provider)(nil)
+	_ vshardrouter.BalancerMetricsProvider       = (*Provider)(nil)
+	_ vshardrouter.StorageUnrefMetricsProvider   = (*Provider)(nil)
+)
+
 // Check that provider implements Collector interface
 var _ prometheus.Collector = (*Provider)(nil)
 
@@ -23,6 +31,16 @@ type Provider struct {
 	retryOnCall *prometheus.CounterVec
 	// requestDuration - histogram for map reduce and single request durations.
 	requestDuration *prometheus.HistogramVec
+	// circuitBreakerState - gauge for replicaset circuit breaker states.
+	circuitBreakerState *prometheus.GaugeVec
+	// limiterQueueDepth - gauge for the number of requests waiting for replicaset limits.
+	limiterQueueDepth *prometheus.GaugeVec
+	// instanceLatency, instanceErrorRate and instanceScore - gauges for balancer statistics of instances.
+	instanceLatency   *prometheus.GaugeVec
+	instanceErrorRate *prometheus.GaugeVec
+	instanceScore     *prometheus.GaugeVec
+	// storageUnrefErrors - counter for failed storage_unref calls of map-reduce.
+	storageUnrefErrors *prometheus.CounterVec
 }
 
 // Describe sends the descriptors of each metric to the provided channel.
@@ -30,6 +48,12 @@ func (pp *Provider) Describe(ch chan<- *prometheus.Desc) {
 	pp.cronDiscoveryEvent.Describe(ch)
 	pp.retryOnCall.Describe(ch)
 	pp.requestDuration.Describe(ch)
+	pp.circuitBreakerState.Describe(ch)
+	pp.limiterQueueDepth.Describe(ch)
+	pp.instanceLatency.Describe(ch)
+	pp.instanceErrorRate.Describe(ch)
+	pp.instanceScore.Describe(ch)
+	pp.storageUnrefErrors.Describe(ch)
 }
 
 // Collect gathers the metrics and sends them to the provided channel.
@@ -37,6 +61,12 @@ func (pp *Provider) Collect(ch chan<- prometheus.Metric) {
 	pp.cronDiscoveryEvent.Collect(ch)
 	pp.retryOnCall.Collect(ch)
 	pp.requestDuration.Collect(ch)
+	pp.circuitBreakerState.Collect(ch)
+	pp.limiterQueueDepth.Collect(ch)
+	pp.instanceLatency.Collect(ch)
+	pp.instanceErrorRate.Collect(ch)
+	pp.instanceScore.Collect(ch)
+	pp.storageUnrefErrors.Collect(ch)
 }
 
 // CronDiscoveryEvent records the duration of a cron discovery event with labels.
@@ -63,6 +93,41 @@ func (pp *Provider) RequestDuration(duration time.Duration, procedure string, ok
 	}).Observe(float64(duration.Milliseconds()))
 }
 
+// CircuitBreakerStateChange sets the circuit breaker state of a replicaset:
+// 0 is closed, 1 is open, 2 is half-open.
+func (pp *Provider) CircuitBreakerStateChange(replicaset string, state vshardrouter.CircuitBreakerState) {
+	pp.circuitBreakerState.With(prometheus.Labels{
+		"replicaset": replicaset,
+	}).Set(float64(state))
+}
+
+// LimiterQueueDepth sets the number of requests waiting for replicaset limits.
+func (pp *Provider) LimiterQueueDepth(replicaset, mode string, depth int) {
+	pp.limiterQueueDepth.With(prometheus.Labels{
+		"replicaset": replicaset,
+		"mode":       mode,
+	}).Set(float64(depth))
+}
+
+// InstanceScore sets the balancer statistics of an instance: average response time in seconds, error rate and score.
+func (pp *Provider) InstanceScore(replicaset, instance string, latency time.Duration, errorRate, score float64) {
+	labels := prometheus.Labels{
+		"replicaset": replicaset,
+		"instance":   instance,
+	}
+
+	pp.instanceLatency.With(labels).Set(latency.Seconds())
+	pp.instanceErrorRate.With(labels).Set(errorRate)
+	pp.instanceScore.With(labels).Set(score)
+}
+
+// StorageUnrefError increments the counter of failed storage_unref calls of a replicaset.
+func (pp *Provider) StorageUnrefError(replicaset string) {
+	pp.storageUnrefErrors.With(prometheus.Labels{
+		"replicaset": replicaset,
+	}).Inc()
+}
+
 // NewPrometheusProvider - is an experimental function.
 // Prometheus Provider is one of the ready-to-use providers implemented
 // for go-vshard-router. It can be used to easily integrate metrics into
@@ -99,5 +164,35 @@ func NewPrometheusProvider() *Provider {
 			Name:      "request_duration",
 			Namespace: "vshard",
 		}, []string{"procedure", "ok", "map_reduce"}), // Histogram for request durations
+
+		circuitBreakerState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:      "circuit_breaker_state",
+			Namespace: "vshard",
+		}, []string{"replicaset"}), // Gauge for circuit breaker states
+
+		limiterQueueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:      "limiter_queue_depth",
+			Namespace: "vshard",
+		}, []string{"replicaset", "mode"}), // Gauge for requests waiting for replicaset limits
+
+		instanceLatency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:      "instance_latency_seconds",
+			Namespace: "vshard",
+		}, []string{"replicaset", "instance"}), // Gauge for average response time of instances
+
+		instanceErrorRate: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:      "instance_error_rate",
+			Namespace: "vshard",
+		}, []string{"replicaset", "instance"}), // Gauge for error rate of instances
+
+		instanceScore: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:      "instance_score",
+			Namespace: "vshard",
+		}, []string{"replicaset", "instance"}), // Gauge for balancer scores of instances
+
+		storageUnrefErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:      "storage_unref_errors",
+			Namespace: "vshard",
+		}, []string{"replicaset"}), // Counter for failed storage_unref calls
 	}
 }
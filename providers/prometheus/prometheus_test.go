@@ -10,6 +10,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/stretchr/testify/require"
+
+	vshardrouter "github.com/tarantool/go-vshard-router/v2"
 )
 
 func TestPrometheusMetricsServer(t *testing.T) {
@@ -24,6 +26,10 @@ func TestPrometheusMetricsServer(t *testing.T) {
 	provider.CronDiscoveryEvent(true, 150*time.Millisecond, "success")
 	provider.RetryOnCall("timeout")
 	provider.RequestDuration(200*time.Millisecond, "test", true, false)
+	provider.CircuitBreakerStateChange("replicaset_1", vshardrouter.CircuitBreakerOpen)
+	provider.LimiterQueueDepth("replicaset_1", "RW", 3)
+	provider.InstanceScore("replicaset_1", "storage_1_a", 100*time.Millisecond, 0.5, 0.6)
+	provider.StorageUnrefError("replicaset_1")
 
 	resp, err := http.Get(server.URL + "/metrics")
 	require.NoError(t, err)
@@ -37,4 +43,10 @@ func TestPrometheusMetricsServer(t *testing.T) {
 	require.Contains(t, metricsOutput, "vshard_request_duration_bucket")
 	require.Contains(t, metricsOutput, "vshard_cron_discovery_event_bucket")
 	require.Contains(t, metricsOutput, "vshard_retry_on_call")
+	require.Contains(t, metricsOutput, `vshard_circuit_breaker_state{replicaset="replicaset_1"} 1`)
+	require.Contains(t, metricsOutput, `vshard_limiter_queue_depth{mode="RW",replicaset="replicaset_1"} 3`)
+	require.Contains(t, metricsOutput, `vshard_instance_latency_seconds{instance="storage_1_a",replicaset="replicaset_1"} 0.1`)
+	require.Contains(t, metricsOutput, `vshard_instance_error_rate{instance="storage_1_a",replicaset="replicaset_1"} 0.5`)
+	require.Contains(t, metricsOutput, `vshard_instance_score{instance="storage_1_a",replicaset="replicaset_1"} 0.6`)
+	require.Contains(t, metricsOutput, `vshard_storage_unref_errors{replicaset="replicaset_1"} 1`)
 }
@@ -19,7 +19,9 @@ type ClusterInfo struct {
 
 type InstanceInfo struct {
 	Cluster string
-	Box     struct {
+	// Zone is an optional zone of the instance, see vshard_router.Config.Zone.
+	Zone string `json:"zone,omitempty" yaml:"zone" mapstructure:"zone"`
+	Box  struct {
 		Listen       string `json:"listen,omitempty" yaml:"listen" mapstructure:"listen"`
 		InstanceUUID string `yaml:"instance_uuid" mapstructure:"instance_uuid" json:"instanceUUID,omitempty"`
 	}
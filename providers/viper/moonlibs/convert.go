@@ -44,6 +44,7 @@ func (cfg *Config) Convert() (map[vshardrouter.ReplicasetInfo][]vshardrouter.Ins
 				Name: instName,
 				Addr: instInfo.Box.Listen,
 				UUID: instUUID,
+				Zone: instInfo.Zone,
 			})
 		}
 
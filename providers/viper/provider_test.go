@@ -39,6 +39,12 @@ func TestNewProviderDirect(t *testing.T) {
 	provider := vprovider.NewProvider(ctx, v, vprovider.ConfigTypeMoonlibs)
 
 	anyProviderValidation(t, provider)
+	require.Equal(t, map[string]string{
+		"storage_1_a": "",
+		"storage_1_b": "dc2",
+		"storage_2_a": "",
+		"storage_2_b": "",
+	}, instanceZones(provider))
 }
 
 func TestNewProviderSub(t *testing.T) {
@@ -73,6 +79,12 @@ func TestNewProvider_Tarantool3(t *testing.T) {
 	provider := vprovider.NewProvider(ctx, v, vprovider.ConfigTypeTarantool3)
 
 	anyProviderValidation(t, provider)
+	require.Equal(t, map[string]string{
+		"storage-a-001": "1",
+		"storage-a-002": "2",
+		"storage-b-001": "1",
+		"storage-b-002": "1",
+	}, instanceZones(provider))
 }
 
 func parseEtcdUrls(strs []string) []url.URL {
@@ -193,3 +205,15 @@ func anyProviderValidation(t testing.TB, provider *vprovider.Provider) {
 		require.NotEmpty(t, instances)
 	}
 }
+
+func instanceZones(provider *vprovider.Provider) map[string]string {
+	zones := make(map[string]string)
+
+	for _, instances := range provider.Topology() {
+		for _, instance := range instances {
+			zones[instance.Name] = instance.Zone
+		}
+	}
+
+	return zones
+}
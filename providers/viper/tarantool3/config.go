@@ -29,6 +29,8 @@ type App struct {
 // Sharding configuration
 type Sharding struct {
 	Roles []string `yaml:"roles"`
+	// Zone is a zone of the instances, it is inherited by replicasets and instances.
+	Zone string `yaml:"zone"`
 }
 
 // Replication configuration
@@ -39,12 +41,14 @@ type Replication struct {
 // Replicaset configuration
 type Replicaset struct {
 	Leader    string              `yaml:"leader"`
+	Sharding  Sharding            `yaml:"sharding"`
 	Instances map[string]Instance `yaml:"instances"`
 }
 
 // Instance in the Replicaset
 type Instance struct {
-	IProto IProto `yaml:"iproto"`
+	IProto   IProto   `yaml:"iproto"`
+	Sharding Sharding `yaml:"sharding"`
 }
 
 // IProto configuration
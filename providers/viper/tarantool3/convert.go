@@ -1,6 +1,7 @@
 package tarantool3
 
 import (
+	"cmp"
 	"fmt"
 
 	vshardrouter "github.com/tarantool/go-vshard-router/v2"
@@ -25,6 +26,8 @@ func (cfg *Config) Convert() (map[vshardrouter.ReplicasetInfo][]vshardrouter.Ins
 			instances = append(instances, vshardrouter.InstanceInfo{
 				Name: instanceName,
 				Addr: instance.IProto.Listen[0].URI,
+				// sharding.zone is inherited from the replicaset and the group levels
+				Zone: cmp.Or(instance.Sharding.Zone, rs.Sharding.Zone, cfg.Groups.Storages.Sharding.Zone),
 			})
 		}
 
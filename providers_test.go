@@ -27,6 +27,30 @@ func TestEmptyMetrics_RequestDuration(t *testing.T) {
 	})
 }
 
+func TestEmptyMetrics_CircuitBreakerStateChange(t *testing.T) {
+	require.NotPanics(t, func() {
+		emptyMetrics.CircuitBreakerStateChange("", vshardrouter.CircuitBreakerOpen)
+	})
+}
+
+func TestEmptyMetrics_LimiterQueueDepth(t *testing.T) {
+	require.NotPanics(t, func() {
+		emptyMetrics.LimiterQueueDepth("", "", 0)
+	})
+}
+
+func TestEmptyMetrics_InstanceScore(t *testing.T) {
+	require.NotPanics(t, func() {
+		emptyMetrics.InstanceScore("", "", 0, 0, 0)
+	})
+}
+
+func TestEmptyMetrics_StorageUnrefError(t *testing.T) {
+	require.NotPanics(t, func() {
+		emptyMetrics.StorageUnrefError("")
+	})
+}
+
 func TestEmptyMetrics_CronDiscoveryEvent(t *testing.T) {
 	require.NotPanics(t, func() {
 		emptyMetrics.CronDiscoveryEvent(false, time.Second, "")
@@ -2,8 +2,12 @@ package vshard_router //nolint:revive
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -47,6 +51,9 @@ func (ri ReplicasetInfo) String() string {
 type ReplicasetCallOpts struct {
 	PoolMode pool.Mode
 	Timeout  time.Duration
+	// Instance is a name of instance to send the request to. PoolMode is ignored if it is set,
+	// unless the pooler can't send requests to the specific instance (pool.ConnectionPool can).
+	Instance string
 }
 
 // Pooler is an interface for the tarantool.Pool wrapper,
@@ -60,10 +67,248 @@ type Pooler interface {
 	GetInfo() map[string]pool.ConnectionInfo
 }
 
+// instancePooler is implemented by poolers that can send a request to the specific instance,
+// e.g. pool.ConnectionPool. Pooler doesn't require it to keep custom poolers compatible:
+// the features that need it (following the master reported in NON_MASTER error, choosing replicas
+// for read requests, polling replicas) are disabled and requests are sent according to pool mode.
+type instancePooler interface {
+	DoInstance(req tarantool.Request, name string) *tarantool.Future
+}
+
 type Replicaset struct {
 	conn              Pooler
 	info              ReplicasetInfo
 	EtalonBucketCount uint64
+
+	// masters, latencies, vclocks, breaker, limiters, health, balancer and zones are pointers to keep Replicaset copyable,
+	// see CalculateEtalonBalance.
+	masters   *masterTracker
+	latencies *latencyTracker
+	vclocks   *vclockCache
+	// breaker is nil if Config.CircuitBreaker is not set.
+	breaker *circuitBreaker
+	// limiters is nil if Config.Limits is not set.
+	limiters *replicasetLimiters
+	// health is nil if Config.ReplicationHealth is not set.
+	health *replicationHealth
+	// balancer is nil if Config.Balancer is not set.
+	balancer *balancer
+	// zones is nil if Config.Zone is not set.
+	zones *zoneRouting
+}
+
+// masterTracker follows the master of replicaset reported by storages in NON_MASTER errors.
+// go-tarantool pool detects instance roles only periodically, so it may send RW requests to the old master
+// for a while after failover. Lua vshard router updates the master immediately in this case (master = 'auto'),
+// so we do the same: RW requests are sent directly to the reported master until it fails.
+type masterTracker struct {
+	// mutex guards instanceUUIDToName, it is used only on topology changes and NON_MASTER error path.
+	mutex              sync.Mutex
+	instanceUUIDToName map[string]string
+
+	// master is a name of the known master, nil means that pool.RW should be used.
+	master atomic.Pointer[string]
+}
+
+func newReplicaset(info ReplicasetInfo, conn Pooler, instances []InstanceInfo) *Replicaset {
+	rs := &Replicaset{
+		info: info,
+		conn: conn,
+		masters: &masterTracker{
+			instanceUUIDToName: make(map[string]string),
+		},
+		latencies: &latencyTracker{},
+		vclocks:   &vclockCache{},
+	}
+
+	for _, instance := range instances {
+		rs.addInstanceInfo(instance)
+	}
+
+	return rs
+}
+
+func (rs *Replicaset) addInstanceInfo(info InstanceInfo) {
+	rs.zones.set(info.Name, info.Zone)
+
+	if rs.masters == nil || info.UUID == uuid.Nil {
+		return
+	}
+
+	rs.masters.mutex.Lock()
+	defer rs.masters.mutex.Unlock()
+
+	rs.masters.instanceUUIDToName[info.UUID.String()] = info.Name
+}
+
+func (rs *Replicaset) removeInstanceInfo(name string) {
+	rs.balancer.forget(name)
+	rs.zones.remove(name)
+
+	if rs.masters == nil {
+		return
+	}
+
+	rs.masters.mutex.Lock()
+	for instanceUUID, instanceName := range rs.masters.instanceUUIDToName {
+		if instanceName == name {
+			delete(rs.masters.instanceUUIDToName, instanceUUID)
+		}
+	}
+	rs.masters.mutex.Unlock()
+
+	rs.resetMaster(name)
+}
+
+// masterName returns the name of the master reported by storage, or an empty string if it is unknown.
+func (rs *Replicaset) masterName() string {
+	if rs.masters == nil {
+		return ""
+	}
+
+	if master := rs.masters.master.Load(); master != nil {
+		return *master
+	}
+
+	return ""
+}
+
+// resetMaster forgets the reported master if it is still equal to name.
+func (rs *Replicaset) resetMaster(name string) {
+	if rs.masters == nil {
+		return
+	}
+
+	if master := rs.masters.master.Load(); master != nil && *master == name {
+		rs.masters.master.CompareAndSwap(master, nil)
+	}
+}
+
+// updateMaster remembers the master reported by storage in NON_MASTER error.
+// masterID is an instance UUID (prior to tnt 3.x) or an instance name. Empty masterID means that
+// the storage doesn't know the master yet, so the reported master is forgotten and pool.RW is used again.
+// It returns false if the master is unknown for the router.
+func (rs *Replicaset) updateMaster(masterID string) bool {
+	if rs.masters == nil {
+		return false
+	}
+
+	if masterID == "" {
+		rs.masters.master.Store(nil)
+		return true
+	}
+
+	rs.masters.mutex.Lock()
+	name, ok := rs.masters.instanceUUIDToName[masterID]
+	rs.masters.mutex.Unlock()
+
+	if !ok {
+		if _, ok = rs.conn.GetInfo()[masterID]; !ok {
+			return false
+		}
+
+		name = masterID
+	}
+
+	rs.masters.master.Store(&name)
+
+	return true
+}
+
+// do sends the request to an instance of replicaset according to mode.
+// RW requests are sent to the master reported by storage, if any.
+func (rs *Replicaset) do(req tarantool.Request, mode pool.Mode) *tarantool.Future {
+	if mode != pool.RW {
+		return rs.conn.Do(req, mode)
+	}
+
+	conn, ok := rs.conn.(instancePooler)
+	if !ok {
+		return rs.conn.Do(req, mode)
+	}
+
+	master := rs.masterName()
+	if master == "" {
+		return rs.conn.Do(req, mode)
+	}
+
+	future := conn.DoInstance(req, master)
+
+	select {
+	case <-future.WaitChan():
+		// DoInstance fails immediately if there is no connection to the instance,
+		// so the request has not been sent, and we can safely send it to another one.
+		if _, err := future.GetResponse(); errors.Is(err, pool.ErrNoHealthyInstance) {
+			rs.resetMaster(master)
+
+			return rs.conn.Do(req, mode)
+		}
+	default:
+	}
+
+	return future
+}
+
+// canDoInstance reports whether requests can be sent to the specific instance of replicaset, see instancePooler.
+func (rs *Replicaset) canDoInstance() bool {
+	_, ok := rs.conn.(instancePooler)
+
+	return ok
+}
+
+// doInstance sends the request to the instance of replicaset, or according to mode if instance is empty
+// or the pooler can't send requests to the specific instance.
+func (rs *Replicaset) doInstance(req tarantool.Request, instance string, mode pool.Mode) *tarantool.Future {
+	if conn, ok := rs.conn.(instancePooler); ok && instance != "" {
+		return conn.DoInstance(req, instance)
+	}
+
+	return rs.do(req, mode)
+}
+
+// orderedInstances returns connected instances suitable for the mode in order of preference:
+// the nearest zones go first if Config.Zone is set, replicas excluded by ReplicationHealthOpts are skipped.
+func (rs *Replicaset) orderedInstances(mode pool.Mode) []string {
+	var replicas, masters []string
+
+	for name, info := range rs.conn.GetInfo() {
+		if !info.ConnectedNow {
+			continue
+		}
+
+		switch {
+		case info.ConnRole != pool.ReplicaRole:
+			masters = append(masters, name)
+		case rs.health.isHealthy(name):
+			replicas = append(replicas, name)
+		}
+	}
+
+	switch mode {
+	case pool.RO:
+		shuffleInstances(replicas)
+		rs.zones.sort(replicas)
+
+		return replicas
+	case pool.ANY:
+		instances := append(replicas, masters...)
+		shuffleInstances(instances)
+		rs.zones.sort(instances)
+
+		return instances
+	default:
+		shuffleInstances(replicas)
+		rs.zones.sort(replicas)
+
+		return append(replicas, masters...)
+	}
+}
+
+func shuffleInstances(instances []string) {
+	//nolint:gosec
+	rand.Shuffle(len(instances), func(i, j int) {
+		instances[i], instances[j] = instances[j], instances[i]
+	})
 }
 
 func (rs *Replicaset) Pooler() pool.Pooler {
@@ -153,7 +398,9 @@ func bucketStatWait(future *tarantool.Future) (BucketStatInfo, error) {
 	return bucketStatResponse.info, nil
 }
 
-// CallAsync sends async request to remote storage
+// CallAsync sends async request to remote storage.
+// If opts.Timeout is set, the timeout context is released by its own timer when the timeout expires,
+// not when the request is completed.
 func (rs *Replicaset) CallAsync(ctx context.Context, opts ReplicasetCallOpts, fnc string, args interface{}) *tarantool.Future {
 	if opts.Timeout > 0 {
 		// Don't set any timeout by default, parent context timeout would be inherited in this case.
@@ -168,7 +415,7 @@ func (rs *Replicaset) CallAsync(ctx context.Context, opts ReplicasetCallOpts, fn
 		Context(ctx).
 		Args(args)
 
-	return rs.conn.Do(req, opts.PoolMode)
+	return rs.doInstance(req, opts.Instance, opts.PoolMode)
 }
 
 func (rs *Replicaset) bucketsDiscoveryAsync(ctx context.Context, from uint64) *tarantool.Future {
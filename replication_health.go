@@ -0,0 +1,314 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+const (
+	replicationHealthPollIntervalDefault = 1 * time.Second
+	replicationHealthMaxLagDefault       = 5 * time.Second
+
+	// replicationUpstreamsEval returns upstreams of an instance from box.info.replication,
+	// it requires lua eval privilege.
+	replicationUpstreamsEval = `
+local upstreams = {}
+for _, r in pairs(box.info.replication) do
+    if r.upstream ~= nil then
+        table.insert(upstreams, {uuid = r.uuid, name = r.name, status = r.upstream.status,
+            lag = r.upstream.lag, idle = r.upstream.idle})
+    end
+end
+return upstreams`
+
+	// upstreamStatusFollow is the only status of a healthy upstream.
+	upstreamStatusFollow = "follow"
+)
+
+// ReplicationHealthOpts enables replication health checks of replicas, as lua vshard does with
+// HIGH_REPLICATION_LAG, OUT_OF_SYNC and UNREACHABLE_MASTER alerts. The router polls box.info.replication
+// of connected replicas and doesn't send CallModeRO, CallModeBRO, CallModeRE and CallModeBRE requests
+// to the replicas whose upstream from the master is broken (its status is not "follow"), lags behind
+// or has not received anything from the master for more than MaxLag.
+// If all replicas of a replicaset are excluded, CallModeRO requests fail with pool.ErrNoRoInstance
+// as if there were no replicas, and the other read requests are sent to the master.
+// The upstreams of all peers are checked if the master is unknown to the router.
+//
+// box.info.replication is requested by lua eval, so the router user must have the privilege to execute it.
+// A replica whose state could not be fetched, or which is disconnected, is excluded until the next successful poll.
+type ReplicationHealthOpts struct {
+	// PollInterval is a pause between polls of replicas. Default is 1s.
+	PollInterval time.Duration
+	// MaxLag is a maximum replication lag of a replica to send read requests to it. Default is 5s.
+	MaxLag time.Duration
+}
+
+// replicationUpstream is an upstream of box.info.replication.
+type replicationUpstream struct {
+	UUID   string  `msgpack:"uuid"`
+	Name   string  `msgpack:"name"`
+	Status string  `msgpack:"status"`
+	Lag    float64 `msgpack:"lag"`
+	Idle   float64 `msgpack:"idle"`
+}
+
+// replicationHealth keeps the replicas of a replicaset that must not be used for read requests.
+type replicationHealth struct {
+	mutex sync.RWMutex
+	// unhealthy maps an instance name to the reason of its exclusion.
+	unhealthy map[string]string
+}
+
+// isHealthy reports whether the instance may be used for read requests, nil health considers all instances healthy.
+func (h *replicationHealth) isHealthy(instance string) bool {
+	if h == nil {
+		return true
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	_, unhealthy := h.unhealthy[instance]
+
+	return !unhealthy
+}
+
+// hasUnhealthy reports whether there are excluded instances.
+func (h *replicationHealth) hasUnhealthy() bool {
+	if h == nil {
+		return false
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return len(h.unhealthy) > 0
+}
+
+// set marks the instance as unhealthy if reason is not empty, or as healthy otherwise.
+// It returns true if the instance has become healthy or unhealthy.
+func (h *replicationHealth) set(instance, reason string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	_, unhealthy := h.unhealthy[instance]
+
+	if reason == "" {
+		delete(h.unhealthy, instance)
+
+		return unhealthy
+	}
+
+	if h.unhealthy == nil {
+		h.unhealthy = make(map[string]string)
+	}
+
+	h.unhealthy[instance] = reason
+
+	return !unhealthy
+}
+
+// retain forgets the instances that are not in instances.
+func (h *replicationHealth) retain(instances map[string]pool.ConnectionInfo) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for name := range h.unhealthy {
+		if _, ok := instances[name]; !ok {
+			delete(h.unhealthy, name)
+		}
+	}
+}
+
+// readTarget chooses an instance for a read request with mode according to the replication health,
+// zones and the balancer. It returns an empty instance if the pool may choose an instance itself.
+// If there are no healthy replicas, pool.RO requests fail with pool.ErrNoRoInstance as they do
+// if there are no connected replicas at all.
+func (rs *Replicaset) readTarget(mode pool.Mode) (string, error) {
+	balanced := rs.balancer != nil && (mode == pool.ANY || mode == pool.PreferRO)
+
+	if !balanced && rs.zones == nil && !rs.health.hasUnhealthy() || !rs.canDoInstance() {
+		return "", nil
+	}
+
+	var instances []string
+	if mode == pool.PreferRO {
+		// masters are used only if there are no replicas
+		instances = rs.orderedInstances(pool.RO)
+	}
+
+	if len(instances) == 0 {
+		instances = rs.orderedInstances(mode)
+	}
+
+	if len(instances) == 0 {
+		if mode == pool.RO {
+			return "", pool.ErrNoRoInstance
+		}
+
+		return "", nil
+	}
+
+	if balanced {
+		return rs.balancer.choose(rs.zones.nearest(instances)), nil
+	}
+
+	return instances[0], nil
+}
+
+// upstreamsHealth returns the reason to exclude a replica with the upstreams, or an empty string if it is healthy.
+func (rs *Replicaset) upstreamsHealth(upstreams []replicationUpstream, master string, maxLag time.Duration) string {
+	var fromMaster []replicationUpstream
+
+	if master != "" {
+		for _, upstream := range upstreams {
+			name := upstream.Name
+			if rs.masters != nil {
+				rs.masters.mutex.Lock()
+				if uuidName, ok := rs.masters.instanceUUIDToName[upstream.UUID]; ok {
+					name = uuidName
+				}
+				rs.masters.mutex.Unlock()
+			}
+
+			if name == master {
+				fromMaster = append(fromMaster, upstream)
+			}
+		}
+	}
+
+	if len(fromMaster) == 0 {
+		// the master is unknown, so check all upstreams
+		fromMaster = upstreams
+	}
+
+	for _, upstream := range fromMaster {
+		if upstream.Status != upstreamStatusFollow {
+			return fmt.Sprintf("upstream %s status is %s", upstream.UUID, upstream.Status)
+		}
+
+		if lag := time.Duration(upstream.Lag * float64(time.Second)); lag > maxLag {
+			return fmt.Sprintf("upstream %s lag %s exceeds %s", upstream.UUID, lag, maxLag)
+		}
+
+		if idle := time.Duration(upstream.Idle * float64(time.Second)); idle > maxLag {
+			return fmt.Sprintf("upstream %s idle %s exceeds %s", upstream.UUID, idle, maxLag)
+		}
+	}
+
+	return ""
+}
+
+// checkReplicationHealth polls replicas of the replicaset and updates their health.
+func (r *Router) checkReplicationHealth(ctx context.Context, rs *Replicaset, opts ReplicationHealthOpts) {
+	if !rs.canDoInstance() {
+		// replicas can't be polled one by one
+		return
+	}
+
+	instances := rs.conn.GetInfo()
+
+	rs.health.retain(instances)
+
+	master := rs.masterName()
+
+	update := func(replica, reason string) {
+		if !rs.health.set(replica, reason) {
+			return
+		}
+
+		if reason != "" {
+			r.log().Warnf(ctx, "Replica %s of replicaset %s is excluded from read requests: %s",
+				replica, rs.info.Name, reason)
+		} else {
+			r.log().Infof(ctx, "Replica %s of replicaset %s is healthy again", replica, rs.info.Name)
+		}
+	}
+
+	var replicas []string
+
+	for name, info := range instances {
+		switch {
+		case !info.ConnectedNow:
+			// the state is unknown until the instance is connected and polled again
+			update(name, "replication state is unknown: instance is disconnected")
+		case info.ConnRole == pool.MasterRole && master == "":
+			master = name
+		case info.ConnRole == pool.ReplicaRole:
+			replicas = append(replicas, name)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.PollInterval)
+	defer cancel()
+
+	req := tarantool.NewEvalRequest(replicationUpstreamsEval).Context(ctx)
+
+	futures := make([]*tarantool.Future, 0, len(replicas))
+	for _, replica := range replicas {
+		futures = append(futures, rs.doInstance(req, replica, pool.RO))
+	}
+
+	for i, future := range futures {
+		var upstreams []replicationUpstream
+
+		if err := future.GetTyped(&[]interface{}{&upstreams}); err != nil {
+			// the last known state is stale, don't rely on it
+			update(replicas[i], fmt.Sprintf("replication state is unknown: %v", err))
+
+			continue
+		}
+
+		update(replicas[i], rs.upstreamsHealth(upstreams, master, opts.MaxLag))
+	}
+}
+
+// cronReplicationHealth polls replication health of all replicasets until ctx is done.
+func (r *Router) cronReplicationHealth(ctx context.Context, opts ReplicationHealthOpts) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = replicationHealthPollIntervalDefault
+	}
+
+	if opts.MaxLag <= 0 {
+		opts.MaxLag = replicationHealthMaxLagDefault
+	}
+
+	// spread the polls of different routers
+	//nolint:gosec
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(opts.PollInterval))))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		var wg sync.WaitGroup
+
+		for _, rs := range r.getNameToReplicaset() {
+			if rs.health == nil {
+				continue
+			}
+
+			wg.Add(1)
+
+			go func(rs *Replicaset) {
+				defer wg.Done()
+
+				r.checkReplicationHealth(ctx, rs, opts)
+			}(rs)
+		}
+
+		wg.Wait()
+
+		timer.Reset(opts.PollInterval)
+	}
+}
@@ -0,0 +1,158 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+func TestReplicaset_upstreamsHealth(t *testing.T) {
+	t.Parallel()
+
+	masterUUID := uuid.New()
+
+	rs := newReplicaset(ReplicasetInfo{Name: "rs"}, nil, []InstanceInfo{
+		{Name: "master", UUID: masterUUID},
+	})
+
+	const maxLag = time.Second
+
+	tests := []struct {
+		name      string
+		upstreams []replicationUpstream
+		master    string
+		healthy   bool
+	}{
+		{
+			name: "master follows",
+			upstreams: []replicationUpstream{
+				{UUID: masterUUID.String(), Status: "follow", Lag: 0.5},
+				{UUID: uuid.NewString(), Status: "disconnected"},
+			},
+			master:  "master",
+			healthy: true,
+		},
+		{
+			name: "master by name",
+			upstreams: []replicationUpstream{
+				{Name: "master", Status: "stopped"},
+				{Name: "replica", Status: "follow"},
+			},
+			master: "master",
+		},
+		{
+			name: "high lag",
+			upstreams: []replicationUpstream{
+				{UUID: masterUUID.String(), Status: "follow", Lag: 1.5},
+			},
+			master: "master",
+		},
+		{
+			name: "master is unreachable",
+			upstreams: []replicationUpstream{
+				{UUID: masterUUID.String(), Status: "follow", Idle: 1.5},
+			},
+			master: "master",
+		},
+		{
+			name: "master is unknown",
+			upstreams: []replicationUpstream{
+				{UUID: uuid.NewString(), Status: "follow"},
+				{UUID: uuid.NewString(), Status: "disconnected"},
+			},
+		},
+		{
+			name:    "no upstreams",
+			master:  "master",
+			healthy: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			reason := rs.upstreamsHealth(tt.upstreams, tt.master, maxLag)
+			require.Equal(t, tt.healthy, reason == "", reason)
+		})
+	}
+}
+
+func TestRouter_Call_ReplicationHealth(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	instances := map[string]pool.ConnectionInfo{
+		"master":    {ConnectedNow: true, ConnRole: pool.MasterRole},
+		"replica_1": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		"replica_2": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+	}
+
+	upstreams := func(status string, lag float64) []interface{} {
+		return []interface{}{[]map[string]interface{}{
+			{"name": "master", "status": status, "lag": lag},
+		}}
+	}
+
+	mPool := newInstancePoolerMock(t)
+	mPool.On("GetInfo").Return(instances)
+
+	// the first poll: replica_1 lags behind
+	mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica_1").
+		Return(newCallResponseFuture(t, upstreams("follow", 10))).Once()
+	mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica_2").
+		Return(newCallResponseFuture(t, upstreams("follow", 0))).Once()
+	mPool.On("DoInstance", mock.MatchedBy(isCallRequest), "replica_2").
+		Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Twice()
+
+	// the second poll: replica_2 is broken
+	mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica_1").
+		Return(newCallResponseFuture(t, upstreams("follow", 10))).Once()
+	mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica_2").
+		Return(newCallResponseFuture(t, upstreams("stopped", 0))).Once()
+	mPool.On("Do", mock.MatchedBy(isCallRequest), pool.RW).
+		Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+	// the third poll: replica_1 has recovered, replica_2 doesn't answer
+	mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica_1").
+		Return(newCallResponseFuture(t, upstreams("follow", 0))).Once()
+	mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica_2").
+		Return(newErrorFuture(pool.ErrNoHealthyInstance)).Once()
+
+	router := newTestRouter(mPool)
+
+	rs := router.getNameToReplicaset()["replicaset_1"]
+	rs.health = &replicationHealth{}
+
+	opts := ReplicationHealthOpts{PollInterval: time.Second, MaxLag: time.Second}
+
+	router.checkReplicationHealth(ctx, rs, opts)
+	require.False(t, rs.health.isHealthy("replica_1"))
+	require.True(t, rs.health.isHealthy("replica_2"))
+
+	_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.NoError(t, err)
+
+	_, err = router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{Hedge: &HedgeOpts{}})
+	require.NoError(t, err)
+
+	// there are no healthy replicas: RO requests fail as if there were no replicas, RE requests use the master
+	router.checkReplicationHealth(ctx, rs, opts)
+
+	_, err = router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.ErrorIs(t, err, pool.ErrNoRoInstance)
+
+	_, err = router.CallRE(ctx, 1, "echo", []interface{}{}, CallOpts{})
+	require.NoError(t, err)
+
+	// the state of the replica that has not answered is unknown
+	router.checkReplicationHealth(ctx, rs, opts)
+	require.True(t, rs.health.isHealthy("replica_1"))
+	require.False(t, rs.health.isHealthy("replica_2"))
+}
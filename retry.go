@@ -0,0 +1,250 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	// RetryErrNameConnection is RetryAttempt.ErrName for connection errors.
+	// Note that the request may have been executed by storage in this case.
+	RetryErrNameConnection = "CONNECTION_ERROR"
+
+	// destinationPollingPause is a pause before the next attempt if storage has sent
+	// a destination replicaset, that is unknown for the router.
+	destinationPollingPause = 50 * time.Millisecond
+)
+
+// RetryAttempt describes a failed attempt of Router.Call.
+type RetryAttempt struct {
+	// Attempt is a number of the failed attempt, starting from 1.
+	Attempt int
+	// Elapsed is a time elapsed since the call has started.
+	Elapsed time.Duration
+	// Err is an error of the failed attempt. It is returned to a caller if the call is not retried.
+	Err error
+	// ErrName is a vshard error name (see VShardErrName constants) if the attempt has failed due to vshard error,
+	// or RetryErrNameConnection if the attempt has failed due to connection error.
+	ErrName string
+	// BucketID is a bucket identifier of the call.
+	BucketID uint64
+	// Mode is a mode of the call.
+	Mode CallMode
+	// Replicaset is a name of replicaset the failed attempt has been sent to.
+	// It is empty if the bucket has not been routed.
+	Replicaset string
+	// Destination is a name of replicaset the bucket has been moved to, if storage has sent it.
+	// It is empty if storage has not sent it or the router doesn't know such replicaset.
+	Destination string
+}
+
+// RetryDecision is a decision of RetryPolicy about the next attempt.
+type RetryDecision struct {
+	// Retry is true if the call should be retried.
+	Retry bool
+	// Delay is a pause before the next attempt.
+	Delay time.Duration
+	// Replicaset is a name of replicaset to send the next attempt to.
+	// If it is empty, the next attempt is routed by bucket id as usual.
+	Replicaset string
+}
+
+// RetryPolicy decides whether Router.Call should retry a failed attempt.
+// Only errors that may disappear on the next attempt are passed to RetryPolicy: routing errors,
+// bucket moves (WRONG_BUCKET, BUCKET_IS_LOCKED, TRANSFER_IS_IN_PROGRESS), NON_MASTER and connection errors.
+// Other errors are returned to a caller immediately. In any case the call is not retried after the call timeout.
+type RetryPolicy interface {
+	NextAttempt(attempt RetryAttempt) RetryDecision
+}
+
+var (
+	_ RetryPolicy = defaultRetryPolicy{}
+	_ RetryPolicy = NoRetryPolicy{}
+	_ RetryPolicy = ExponentialBackoffRetryPolicy{}
+)
+
+// defaultRetryPolicy is used when there is no RetryPolicy in CallOpts and Config.
+// It reproduces the lua vshard router behavior.
+type defaultRetryPolicy struct {
+	routeRetryPause time.Duration
+}
+
+func (p defaultRetryPolicy) NextAttempt(attempt RetryAttempt) RetryDecision {
+	if attempt.Replicaset == "" {
+		// The lua vshard router just yields here and retires, no pause is applied.
+		// But without a pause we may have few problems, see: https://github.com/tarantool/go-vshard-router/issues/66.
+		return RetryDecision{Retry: true, Delay: p.routeRetryPause}
+	}
+
+	var vshardError *StorageCallVShardError
+	_ = errors.As(attempt.Err, &vshardError)
+
+	switch attempt.ErrName {
+	case RetryErrNameConnection:
+		// Only read requests with preference for a replica are retried on another instance, as lua vshard router does.
+		return RetryDecision{Retry: attempt.Mode == CallModeRE, Delay: connectionRetryPause}
+	case VShardErrNameWrongBucket, VShardErrNameBucketIsLocked, VShardErrNameTransferIsInProgress:
+		// We reproduce here behavior in https://github.com/tarantool/vshard/blob/0.1.34/vshard/router/init.lua#L667
+		if vshardError != nil && vshardError.Destination != "" && attempt.Destination == "" {
+			// Wait for the topology update.
+			return RetryDecision{Retry: true, Delay: destinationPollingPause}
+		}
+
+		return RetryDecision{Retry: true}
+	case VShardErrNameNonMaster:
+		if vshardError != nil && vshardError.MasterUUID == "" {
+			// The storage doesn't know the master yet, give the replicaset time to elect it.
+			return RetryDecision{Retry: true, Delay: connectionRetryPause}
+		}
+
+		return RetryDecision{Retry: true}
+	default:
+		return RetryDecision{}
+	}
+}
+
+// NoRetryPolicy never retries Router.Call.
+type NoRetryPolicy struct{}
+
+// NextAttempt implements RetryPolicy interface.
+func (NoRetryPolicy) NextAttempt(_ RetryAttempt) RetryDecision {
+	return RetryDecision{}
+}
+
+// ExponentialBackoffRetryPolicy retries Router.Call with exponentially growing delays and full jitter:
+// the delay before the n-th retry is a random value in [0, min(MaxDelay, BaseDelay * 2^(n-1))].
+type ExponentialBackoffRetryPolicy struct {
+	// BaseDelay is an upper bound of the delay before the first retry. Default is 10ms.
+	BaseDelay time.Duration
+	// MaxDelay is a maximum upper bound of the delay. Default is 1s.
+	MaxDelay time.Duration
+	// MaxAttempts limits the number of attempts. Default is 0, the number of attempts is limited only by the call timeout.
+	MaxAttempts int
+	// RetryConnectionErrors enables retries on connection errors.
+	// Enable it only for idempotent requests, since the request may have been executed by storage.
+	RetryConnectionErrors bool
+}
+
+// NextAttempt implements RetryPolicy interface.
+func (p ExponentialBackoffRetryPolicy) NextAttempt(attempt RetryAttempt) RetryDecision {
+	const baseDelayDefault = 10 * time.Millisecond
+	const maxDelayDefault = 1 * time.Second
+
+	if p.MaxAttempts > 0 && attempt.Attempt >= p.MaxAttempts {
+		return RetryDecision{}
+	}
+
+	if attempt.ErrName == RetryErrNameConnection && !p.RetryConnectionErrors {
+		return RetryDecision{}
+	}
+
+	baseDelay := p.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = baseDelayDefault
+	}
+
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = maxDelayDefault
+	}
+
+	delay := baseDelay
+	for i := 1; i < attempt.Attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	//nolint:gosec
+	delay = time.Duration(rand.Int63n(int64(delay) + 1))
+
+	return RetryDecision{Retry: true, Delay: delay}
+}
+
+// retryPolicy returns RetryPolicy for the call: CallOpts has the highest priority, then Config.
+func (r *Router) retryPolicy(opts CallOpts) RetryPolicy {
+	if opts.RetryPolicy != nil {
+		return opts.RetryPolicy
+	}
+
+	if r.cfg.RetryPolicy != nil {
+		return r.cfg.RetryPolicy
+	}
+
+	return defaultRetryPolicy{routeRetryPause: opts.RouteRetryPause}
+}
+
+// waitRetry asks policy whether the failed attempt should be retried and waits for the delay it has chosen.
+// It returns false if the call should not be retried, otherwise it returns a replicaset
+// for the next attempt (nil means that the next attempt should be routed by bucket id).
+func (r *Router) waitRetry(ctx context.Context, policy RetryPolicy, reason string,
+	attempt RetryAttempt, timeout time.Duration) (*Replicaset, bool) {
+	decision, ok := r.retryDecision(ctx, policy, reason, attempt, timeout)
+	if !ok || !retryPause(ctx, decision.Delay) {
+		return nil, false
+	}
+
+	return r.retryReplicaset(ctx, decision, attempt.BucketID), true
+}
+
+// retryDecision asks policy whether the failed attempt should be retried.
+// It returns false if the call should not be retried, including the case when the delay exceeds the call timeout.
+func (r *Router) retryDecision(ctx context.Context, policy RetryPolicy, reason string,
+	attempt RetryAttempt, timeout time.Duration) (RetryDecision, bool) {
+	if ctx.Err() != nil {
+		return RetryDecision{}, false
+	}
+
+	decision := policy.NextAttempt(attempt)
+	if !decision.Retry {
+		return RetryDecision{}, false
+	}
+
+	if attempt.Elapsed+decision.Delay > timeout {
+		// The next attempt would be after the call timeout anyway.
+		return RetryDecision{}, false
+	}
+
+	r.metrics().RetryOnCall(reason)
+
+	r.log().Debugf(ctx, "Retrying attempt %d after %s cause got error: %v", attempt.Attempt, decision.Delay, attempt.Err)
+
+	return decision, true
+}
+
+// retryPause waits for delay, it returns false if ctx is done earlier.
+func retryPause(ctx context.Context, delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// retryReplicaset returns a replicaset chosen by retry policy for the next attempt,
+// nil means that the next attempt should be routed by bucket id.
+func (r *Router) retryReplicaset(ctx context.Context, decision RetryDecision, bucketID uint64) *Replicaset {
+	if decision.Replicaset == "" {
+		return nil
+	}
+
+	rs := r.getNameToReplicaset()[decision.Replicaset]
+	if rs == nil {
+		r.log().Warnf(ctx, "Replicaset '%s' chosen by retry policy was not found, route bucket %d as usual",
+			decision.Replicaset, bucketID)
+	}
+
+	return rs
+}
@@ -0,0 +1,173 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+// retryPolicyFunc is a RetryPolicy for tests.
+type retryPolicyFunc func(attempt RetryAttempt) RetryDecision
+
+func (f retryPolicyFunc) NextAttempt(attempt RetryAttempt) RetryDecision {
+	return f(attempt)
+}
+
+func TestExponentialBackoffRetryPolicy_NextAttempt(t *testing.T) {
+	t.Parallel()
+
+	policy := ExponentialBackoffRetryPolicy{
+		BaseDelay:   10 * time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+		MaxAttempts: 5,
+	}
+
+	for attempt := 1; attempt < 5; attempt++ {
+		decision := policy.NextAttempt(RetryAttempt{Attempt: attempt, ErrName: VShardErrNameWrongBucket})
+		require.True(t, decision.Retry)
+		require.GreaterOrEqual(t, decision.Delay, time.Duration(0))
+		require.LessOrEqual(t, decision.Delay, min(10*time.Millisecond<<(attempt-1), 50*time.Millisecond))
+		require.Empty(t, decision.Replicaset)
+	}
+
+	require.False(t, policy.NextAttempt(RetryAttempt{Attempt: 5, ErrName: VShardErrNameWrongBucket}).Retry)
+	require.False(t, policy.NextAttempt(RetryAttempt{Attempt: 1, ErrName: RetryErrNameConnection}).Retry)
+
+	policy.RetryConnectionErrors = true
+	require.True(t, policy.NextAttempt(RetryAttempt{Attempt: 1, ErrName: RetryErrNameConnection}).Retry)
+}
+
+func TestNoRetryPolicy_NextAttempt(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, NoRetryPolicy{}.NextAttempt(RetryAttempt{Attempt: 1, ErrName: VShardErrNameWrongBucket}).Retry)
+}
+
+func TestRouter_Call_RetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	wrongBucketErr := StorageCallVShardError{
+		BucketID:    1,
+		Name:        VShardErrNameWrongBucket,
+		Code:        VShardErrCodeWrongBucket,
+		Destination: "replicaset_1",
+	}
+
+	t.Run("no retry", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{RetryPolicy: NoRetryPolicy{}})
+
+		var vshardError *StorageCallVShardError
+		require.ErrorAs(t, err, &vshardError)
+		require.Equal(t, VShardErrNameWrongBucket, vshardError.Name)
+	})
+
+	t.Run("config policy", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Twice()
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		var attempts []RetryAttempt
+
+		router := newTestRouter(mPool)
+		router.cfg.RetryPolicy = retryPolicyFunc(func(attempt RetryAttempt) RetryDecision {
+			attempts = append(attempts, attempt)
+
+			return RetryDecision{Retry: true}
+		})
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+
+		require.Len(t, attempts, 2)
+		for i, attempt := range attempts {
+			require.Equal(t, i+1, attempt.Attempt)
+			require.Equal(t, VShardErrNameWrongBucket, attempt.ErrName)
+			require.Equal(t, uint64(1), attempt.BucketID)
+			require.Equal(t, CallModeRW, attempt.Mode)
+			require.Equal(t, "replicaset_1", attempt.Replicaset)
+			require.Equal(t, "replicaset_1", attempt.Destination)
+		}
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newErrorFuture(tarantool.ClientError{Code: tarantool.ErrConnectionClosed})).Once()
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{
+			RetryPolicy: ExponentialBackoffRetryPolicy{RetryConnectionErrors: true},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("policy chooses replicaset", func(t *testing.T) {
+		t.Parallel()
+
+		mPool1 := mockpool.NewPooler(t)
+		mPool1.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Once()
+
+		mPool2 := mockpool.NewPooler(t)
+		mPool2.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool1)
+		nameToReplicaset := router.getNameToReplicaset()
+		nameToReplicaset["replicaset_2"] = newReplicaset(ReplicasetInfo{Name: "replicaset_2"}, mPool2, nil)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{
+			RetryPolicy: retryPolicyFunc(func(_ RetryAttempt) RetryDecision {
+				return RetryDecision{Retry: true, Replicaset: "replicaset_2"}
+			}),
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("delay exceeds timeout", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{nil, wrongBucketErr})).Once()
+
+		router := newTestRouter(mPool)
+
+		start := time.Now()
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{
+			Timeout: 100 * time.Millisecond,
+			RetryPolicy: retryPolicyFunc(func(_ RetryAttempt) RetryDecision {
+				return RetryDecision{Retry: true, Delay: time.Second}
+			}),
+		})
+		require.Error(t, err)
+		require.Less(t, time.Since(start), time.Second)
+	})
+}
@@ -0,0 +1,278 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// vclockEval returns the vclock of an instance, it requires lua eval privilege.
+const vclockEval = "return box.info.vclock"
+
+// VClock is a vector clock of tarantool instance (box.info.vclock): instance id -> LSN.
+type VClock map[uint64]uint64
+
+// DecodeMsgpack decodes box.info.vclock, that is encoded as an array or as a map depending on its ids.
+func (v *VClock) DecodeMsgpack(d *msgpack.Decoder) error {
+	code, err := d.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32 {
+		var lsns []uint64
+		if err := d.Decode(&lsns); err != nil {
+			return err
+		}
+
+		*v = make(VClock, len(lsns))
+		for i, lsn := range lsns {
+			(*v)[uint64(i)+1] = lsn
+		}
+
+		return nil
+	}
+
+	var lsns map[uint64]uint64
+	if err := d.Decode(&lsns); err != nil {
+		return err
+	}
+
+	*v = lsns
+
+	return nil
+}
+
+// Follows reports whether v has caught up with other. Component 0 is ignored since it is not replicated.
+func (v VClock) Follows(other VClock) bool {
+	for id, lsn := range other {
+		if id != 0 && v[id] < lsn {
+			return false
+		}
+	}
+
+	return true
+}
+
+// merge raises v components up to other ones.
+func (v VClock) merge(other VClock) {
+	for id, lsn := range other {
+		if v[id] < lsn {
+			v[id] = lsn
+		}
+	}
+}
+
+// ConsistencyToken describes writes to a replicaset: the reads of the session must see the state
+// that is not older than VClock.
+type ConsistencyToken struct {
+	Replicaset string
+	VClock     VClock
+}
+
+// Session provides read-your-writes consistency for calls with CallOpts.Session.
+// After a successful CallModeRW call the session remembers the vclock of the replicaset master,
+// and the following read calls of the session are sent only to the replicas that have caught up with it,
+// or to the master if there are no such replicas. Hedging is not used for read calls with the session token.
+//
+// The vclocks are requested by lua eval, so the router user must have the privilege to execute it.
+// Otherwise, or if the vclock could not be fetched for any other reason, the read calls of the session are
+// sent to the master of the replicaset. Tokens captured on the storage side (e.g. the commit LSN returned by
+// a user function) may be passed to the session by Observe.
+//
+// The consistency has a cost: every CallModeRW call of the session waits for one more request to the master
+// after the call itself, and a read call asks all the connected replicas for their vclocks at once, unless
+// a replica is already known to have caught up with the session. Use sessions only for the calls that need
+// to read their writes.
+//
+// Session is safe for concurrent use.
+type Session struct {
+	mutex  sync.Mutex
+	tokens map[string]*sessionToken
+}
+
+type sessionToken struct {
+	vclock VClock
+	// masterOnly is true if the vclock of some write is unknown, so only the master is suitable for reads.
+	masterOnly bool
+}
+
+// NewSession creates an empty session.
+func NewSession() *Session {
+	return &Session{
+		tokens: make(map[string]*sessionToken),
+	}
+}
+
+// Observe adds the token to the session, the following read calls of the session
+// will see the state of the replicaset that is not older than the token.
+func (s *Session) Observe(token ConsistencyToken) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tokenLocked(token.Replicaset).vclock.merge(token.VClock)
+}
+
+// Tokens returns the tokens of the session, e.g. to pass them to another session.
+// The token of a replicaset is missing if the session must read from its master.
+func (s *Session) Tokens() []ConsistencyToken {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokens := make([]ConsistencyToken, 0, len(s.tokens))
+
+	for rsName, token := range s.tokens {
+		if token.masterOnly {
+			continue
+		}
+
+		tokens = append(tokens, ConsistencyToken{
+			Replicaset: rsName,
+			VClock:     maps.Clone(token.vclock),
+		})
+	}
+
+	return tokens
+}
+
+// tokenLocked must be called with mutex locked.
+func (s *Session) tokenLocked(rsName string) *sessionToken {
+	token, ok := s.tokens[rsName]
+	if !ok {
+		token = &sessionToken{vclock: make(VClock)}
+		s.tokens[rsName] = token
+	}
+
+	return token
+}
+
+// token returns a copy of the token of the replicaset, nil session has no tokens.
+func (s *Session) token(rsName string) (sessionToken, bool) {
+	if s == nil {
+		return sessionToken{}, false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token, ok := s.tokens[rsName]
+	if !ok {
+		return sessionToken{}, false
+	}
+
+	return sessionToken{vclock: maps.Clone(token.vclock), masterOnly: token.masterOnly}, true
+}
+
+func (s *Session) requireMaster(rsName string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.tokenLocked(rsName).masterOnly = true
+}
+
+// vclockCache keeps the last known vclocks of replicaset instances.
+// A vclock never decreases, so an instance that has caught up with a token once doesn't need to be asked again.
+type vclockCache struct {
+	mutex   sync.Mutex
+	vclocks map[string]VClock
+}
+
+func (vc *vclockCache) get(instance string) VClock {
+	if vc == nil {
+		return nil
+	}
+
+	vc.mutex.Lock()
+	defer vc.mutex.Unlock()
+
+	return vc.vclocks[instance]
+}
+
+func (vc *vclockCache) set(instance string, vclock VClock) {
+	if vc == nil {
+		return
+	}
+
+	vc.mutex.Lock()
+	defer vc.mutex.Unlock()
+
+	if vc.vclocks == nil {
+		vc.vclocks = make(map[string]VClock)
+	}
+
+	vc.vclocks[instance] = vclock
+}
+
+func vclockWait(future *tarantool.Future) (VClock, error) {
+	var vclock VClock
+
+	if err := future.GetTyped(&[]interface{}{&vclock}); err != nil {
+		return nil, fmt.Errorf("failed to get vclock: %w", err)
+	}
+
+	return vclock, nil
+}
+
+// observeWrite remembers the vclock of the replicaset master in the session after a successful write.
+func (r *Router) observeWrite(ctx context.Context, rs *Replicaset, session *Session) {
+	req := tarantool.NewEvalRequest(vclockEval).Context(ctx)
+
+	vclock, err := vclockWait(rs.do(req, pool.RW))
+	if err != nil {
+		r.log().Warnf(ctx, "Session reads from replicaset %s are sent to the master: %v", rs.info.Name, err)
+		session.requireMaster(rs.info.Name)
+
+		return
+	}
+
+	session.Observe(ConsistencyToken{Replicaset: rs.info.Name, VClock: vclock})
+}
+
+// caughtUpReplica returns a replica of the replicaset that has caught up with the token,
+// or an empty string if there is no such replica, and the request should be sent to the master.
+func (r *Router) caughtUpReplica(ctx context.Context, rs *Replicaset, token sessionToken) string {
+	if token.masterOnly || !rs.canDoInstance() {
+		return ""
+	}
+
+	replicas := rs.orderedInstances(pool.RO)
+
+	for _, replica := range replicas {
+		if rs.vclocks.get(replica).Follows(token.vclock) {
+			return replica
+		}
+	}
+
+	req := tarantool.NewEvalRequest(vclockEval).Context(ctx)
+
+	// ask all replicas at once, but keep the order of preference
+	futures := make([]*tarantool.Future, 0, len(replicas))
+	for _, replica := range replicas {
+		futures = append(futures, rs.doInstance(req, replica, pool.RO))
+	}
+
+	for i, future := range futures {
+		vclock, err := vclockWait(future)
+		if err != nil {
+			r.log().Debugf(ctx, "Can't check replica %s of replicaset %s: %v", replicas[i], rs.info.Name, err)
+
+			continue
+		}
+
+		rs.vclocks.set(replicas[i], vclock)
+
+		if vclock.Follows(token.vclock) {
+			return replicas[i]
+		}
+	}
+
+	r.log().Debugf(ctx, "No replicas of replicaset %s have caught up with the session, call master", rs.info.Name)
+
+	return ""
+}
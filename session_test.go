@@ -0,0 +1,152 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestVClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("decode array", func(t *testing.T) {
+		t.Parallel()
+
+		bts, err := msgpack.Marshal([]uint64{10, 0, 5})
+		require.NoError(t, err)
+
+		var vclock VClock
+		require.NoError(t, msgpack.Unmarshal(bts, &vclock))
+		require.Equal(t, VClock{1: 10, 2: 0, 3: 5}, vclock)
+	})
+
+	t.Run("decode map", func(t *testing.T) {
+		t.Parallel()
+
+		bts, err := msgpack.Marshal(map[uint64]uint64{0: 3, 2: 7})
+		require.NoError(t, err)
+
+		var vclock VClock
+		require.NoError(t, msgpack.Unmarshal(bts, &vclock))
+		require.Equal(t, VClock{0: 3, 2: 7}, vclock)
+	})
+
+	t.Run("follows", func(t *testing.T) {
+		t.Parallel()
+
+		require.True(t, VClock{1: 10, 2: 5}.Follows(VClock{1: 10}))
+		require.True(t, VClock{1: 10}.Follows(VClock{0: 100, 1: 9}))
+		require.False(t, VClock{1: 10}.Follows(VClock{1: 10, 2: 1}))
+		require.False(t, VClock(nil).Follows(VClock{1: 1}))
+		require.True(t, VClock(nil).Follows(nil))
+	})
+}
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	session := NewSession()
+	session.Observe(ConsistencyToken{Replicaset: "rs", VClock: VClock{1: 10, 2: 3}})
+	session.Observe(ConsistencyToken{Replicaset: "rs", VClock: VClock{1: 5, 2: 7}})
+
+	require.Equal(t, []ConsistencyToken{{Replicaset: "rs", VClock: VClock{1: 10, 2: 7}}}, session.Tokens())
+
+	session.requireMaster("rs")
+	require.Empty(t, session.Tokens())
+
+	token, ok := session.token("rs")
+	require.True(t, ok)
+	require.True(t, token.masterOnly)
+}
+
+func isEvalRequest(req *tarantool.EvalRequest) bool {
+	return req != nil
+}
+
+func isCallRequest(req *tarantool.CallRequest) bool {
+	return req != nil
+}
+
+func TestRouter_Call_Session(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	replicas := map[string]pool.ConnectionInfo{
+		"master":  {ConnectedNow: true, ConnRole: pool.MasterRole},
+		"replica": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+	}
+
+	t.Run("replica has caught up", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("Do", mock.MatchedBy(isCallRequest), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+		mPool.On("Do", mock.MatchedBy(isEvalRequest), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{map[uint64]uint64{1: 10}})).Once()
+		mPool.On("GetInfo").Return(replicas)
+		mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica").
+			Return(newCallResponseFuture(t, []interface{}{[]uint64{10}})).Once()
+		mPool.On("DoInstance", mock.MatchedBy(isCallRequest), "replica").
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Twice()
+
+		router := newTestRouter(mPool)
+		session := NewSession()
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{Session: session})
+		require.NoError(t, err)
+		require.Equal(t, []ConsistencyToken{{Replicaset: "replicaset_1", VClock: VClock{1: 10}}}, session.Tokens())
+
+		_, err = router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{Session: session})
+		require.NoError(t, err)
+
+		// the vclock of the replica is cached
+		_, err = router.CallBRO(ctx, 1, "echo", []interface{}{}, CallOpts{Session: session})
+		require.NoError(t, err)
+	})
+
+	t.Run("replica lags behind", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(replicas)
+		mPool.On("DoInstance", mock.MatchedBy(isEvalRequest), "replica").
+			Return(newCallResponseFuture(t, []interface{}{[]uint64{9}})).Once()
+		mPool.On("Do", mock.MatchedBy(isCallRequest), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+		session := NewSession()
+		session.Observe(ConsistencyToken{Replicaset: "replicaset_1", VClock: VClock{1: 10}})
+
+		_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{Session: session})
+		require.NoError(t, err)
+	})
+
+	t.Run("unknown vclock", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("Do", mock.MatchedBy(isCallRequest), pool.RW).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Twice()
+		mPool.On("Do", mock.MatchedBy(isEvalRequest), pool.RW).
+			Return(newErrorFuture(errors.New("access denied"))).Once()
+
+		router := newTestRouter(mPool)
+		session := NewSession()
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{Session: session})
+		require.NoError(t, err)
+
+		// the replicas are not asked at all
+		_, err = router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{Session: session})
+		require.NoError(t, err)
+	})
+}
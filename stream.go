@@ -0,0 +1,97 @@
+package vshard_router //nolint:revive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// CallPush is a message sent by user defined function with box.session.push.
+type CallPush struct {
+	resp tarantool.Response
+}
+
+// Get returns a value pushed by user defined function.
+func (p CallPush) Get() (interface{}, error) {
+	var result interface{}
+	err := p.GetTyped(&result)
+
+	return result, err
+}
+
+// GetTyped decodes a value pushed by user defined function into result.
+func (p CallPush) GetTyped(result interface{}) error {
+	return p.resp.DecodeTyped(&pushValueDecoder{result: result})
+}
+
+// pushValueDecoder decodes the only element of push message body into result.
+type pushValueDecoder struct {
+	result interface{}
+}
+
+func (p *pushValueDecoder) DecodeMsgpack(d *msgpack.Decoder) error {
+	n, err := d.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	if n != 1 {
+		return fmt.Errorf("protocol violation: invalid push message length: %d", n)
+	}
+
+	return d.Decode(p.result)
+}
+
+// callPushError wraps an error returned by push handler to return it to a caller as is.
+type callPushError struct {
+	err error
+}
+
+func (e callPushError) Error() string {
+	return e.err.Error()
+}
+
+func (e callPushError) Unwrap() error {
+	return e.err
+}
+
+// CallStream acts like Router.Call, but passes messages sent by user defined function with box.session.push
+// to onPush before the final result is returned. The same routing and retry rules are applied,
+// but the call is never retried once a push message has been passed to onPush.
+// If onPush returns an error, the call is aborted and the error is returned as is.
+// Note that opts.Timeout limits the whole call, including all push messages.
+//
+// CallStream does not apply backpressure: the connection buffers every push message of the call
+// in memory until the call is finished, however slow onPush is. Aborting the call does not stop
+// the user defined function on the storage either. Use it for a bounded number of push messages,
+// split large results into several calls.
+func (r *Router) CallStream(ctx context.Context, bucketID uint64, mode CallMode, fnc string, args interface{},
+	opts CallOpts, onPush func(push CallPush) error) (VshardRouterCallResp, error) {
+	if onPush == nil {
+		return VshardRouterCallResp{}, fmt.Errorf("onPush is nil")
+	}
+
+	return r.call(ctx, bucketID, mode, fnc, args, opts, onPush)
+}
+
+// readPushes passes push messages of the future to onPush and then decodes the final response into resp.
+func readPushes(future *tarantool.Future, onPush func(push CallPush) error,
+	pushed *bool, resp *vshardStorageCallResponseProto) error {
+	it := future.GetIterator()
+
+	for it.Next() {
+		if !it.IsPush() {
+			break
+		}
+
+		*pushed = true
+
+		if err := onPush(CallPush{resp: it.Value()}); err != nil {
+			return callPushError{err: err}
+		}
+	}
+
+	return future.GetTyped(resp)
+}
@@ -0,0 +1,137 @@
+package vshard_router // nolint: revive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2"
+	"github.com/tarantool/go-tarantool/v2/pool"
+	"github.com/vmihailenco/msgpack/v5"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
+)
+
+// newPushResponseFuture creates a future with push messages that is resolved with IPROTO_DATA body containing data.
+func newPushResponseFuture(t testing.TB, pushes []interface{}, data interface{}) *tarantool.Future {
+	const iprotoData = 0x30
+
+	future := tarantool.NewFuture(tarantool.NewCallRequest("vshard.storage.call"))
+
+	for _, push := range pushes {
+		bts, err := msgpack.Marshal(map[int]interface{}{iprotoData: []interface{}{push}})
+		require.NoError(t, err)
+
+		err = future.AppendPush(tarantool.Header{}, bytes.NewReader(bts))
+		require.NoError(t, err)
+	}
+
+	bts, err := msgpack.Marshal(map[int]interface{}{iprotoData: data})
+	require.NoError(t, err)
+
+	err = future.SetResponse(tarantool.Header{}, bytes.NewReader(bts))
+	require.NoError(t, err)
+
+	return future
+}
+
+func TestRouter_CallStream(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	wrongBucketErr := StorageCallVShardError{
+		BucketID:    1,
+		Name:        VShardErrNameWrongBucket,
+		Code:        VShardErrCodeWrongBucket,
+		Destination: "replicaset_1",
+	}
+
+	t.Run("pushes and result", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).
+			Return(newPushResponseFuture(t, []interface{}{1, 2, 3}, []interface{}{true, "done"})).Once()
+
+		router := newTestRouter(mPool)
+
+		var pushes []int
+
+		resp, err := router.CallStream(ctx, 1, CallModeRO, "export", []interface{}{}, CallOpts{},
+			func(push CallPush) error {
+				var value int
+				if err := push.GetTyped(&value); err != nil {
+					return err
+				}
+
+				pushes = append(pushes, value)
+
+				return nil
+			})
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 2, 3}, pushes)
+
+		result, err := resp.Get()
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"done"}, result)
+	})
+
+	t.Run("push handler error", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RO).
+			Return(newPushResponseFuture(t, []interface{}{1, 2, 3}, []interface{}{true, "done"})).Once()
+
+		router := newTestRouter(mPool)
+
+		errStop := errors.New("stop")
+
+		_, err := router.CallStream(ctx, 1, CallModeRO, "export", []interface{}{}, CallOpts{},
+			func(_ CallPush) error {
+				return errStop
+			})
+		require.ErrorIs(t, err, errStop)
+	})
+
+	t.Run("retry before pushes", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newPushResponseFuture(t, nil, []interface{}{nil, wrongBucketErr})).Once()
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newPushResponseFuture(t, []interface{}{"push"}, []interface{}{true})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallStream(ctx, 1, CallModeRW, "export", []interface{}{}, CallOpts{},
+			func(_ CallPush) error {
+				return nil
+			})
+		require.NoError(t, err)
+	})
+
+	t.Run("no retry after pushes", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Do", mock.Anything, pool.RW).
+			Return(newPushResponseFuture(t, []interface{}{"push"}, []interface{}{nil, wrongBucketErr})).Once()
+
+		router := newTestRouter(mPool)
+
+		_, err := router.CallStream(ctx, 1, CallModeRW, "export", []interface{}{}, CallOpts{},
+			func(_ CallPush) error {
+				return nil
+			})
+
+		var vshardError *StorageCallVShardError
+		require.ErrorAs(t, err, &vshardError)
+		require.Equal(t, VShardErrNameWrongBucket, vshardError.Name)
+	})
+}
@@ -276,6 +276,84 @@ func TestRouter_Call(t *testing.T) {
 		require.NotNil(t, err, "RouterCall raise_client_error finished with err")
 	})
 
+	t.Run("router.CallRE", func(t *testing.T) {
+		args := []interface{}{"arg"}
+
+		resp, err := router.CallRE(ctx, bucketID, "echo", args, vshardrouter.CallOpts{})
+		require.NoError(t, err, "router.CallRE with no err")
+
+		var result string
+		err = resp.GetTyped(&[]interface{}{&result})
+		require.NoError(t, err, "GetTyped with no err")
+		require.Equal(t, "arg", result)
+	})
+
+	t.Run("router.CallBRO with hedging", func(t *testing.T) {
+		args := []interface{}{"arg"}
+
+		resp, err := router.CallBRO(ctx, bucketID, "echo", args, vshardrouter.CallOpts{
+			Hedge: &vshardrouter.HedgeOpts{Delay: time.Millisecond},
+		})
+		require.NoError(t, err, "router.CallBRO with no err")
+
+		var result string
+		err = resp.GetTyped(&[]interface{}{&result})
+		require.NoError(t, err, "GetTyped with no err")
+		require.Equal(t, "arg", result)
+	})
+
+	t.Run("CallTypedRW", func(t *testing.T) {
+		result, err := vshardrouter.CallTypedRW[string](router, ctx, bucketID, "echo", []interface{}{"arg"},
+			vshardrouter.CallOpts{})
+		require.NoError(t, err, "CallTypedRW with no err")
+		require.Equal(t, "arg", result)
+
+		_, _, err = vshardrouter.CallTyped2[string, string](router, ctx, bucketID, vshardrouter.CallModeRW, "echo",
+			[]interface{}{"arg"}, vshardrouter.CallOpts{})
+		require.ErrorAs(t, err, &vshardrouter.CallArityError{}, "CallTyped2 with arity error")
+	})
+
+	t.Run("router.CallRO with session", func(t *testing.T) {
+		session := vshardrouter.NewSession()
+
+		_, err := router.CallRW(ctx, bucketID, "echo", []interface{}{"arg"}, vshardrouter.CallOpts{Session: session})
+		require.NoError(t, err, "router.CallRW with no err")
+		require.Len(t, session.Tokens(), 1, "session has a token after write")
+
+		resp, err := router.CallRO(ctx, bucketID, "echo", []interface{}{"arg"}, vshardrouter.CallOpts{Session: session})
+		require.NoError(t, err, "router.CallRO with no err")
+
+		var result string
+		err = resp.GetTyped(&[]interface{}{&result})
+		require.NoError(t, err, "GetTyped with no err")
+		require.Equal(t, "arg", result)
+	})
+
+	t.Run("router.CallStream", func(t *testing.T) {
+		args := []interface{}{"a", "b", "c"}
+
+		var pushes []string
+
+		resp, err := router.CallStream(ctx, bucketID, vshardrouter.CallModeRW, "push_echo", args, vshardrouter.CallOpts{},
+			func(push vshardrouter.CallPush) error {
+				var value string
+				if err := push.GetTyped(&value); err != nil {
+					return err
+				}
+
+				pushes = append(pushes, value)
+
+				return nil
+			})
+		require.NoError(t, err, "router.CallStream with no err")
+		require.Equal(t, []string{"a", "b", "c"}, pushes)
+
+		var count int
+		err = resp.GetTyped(&[]interface{}{&count})
+		require.NoError(t, err, "GetTyped with no err")
+		require.Equal(t, 3, count)
+	})
+
 	t.Run("router.Call simulate vshard error", func(t *testing.T) {
 		rsMap := router.RouteAll()
 
@@ -295,6 +373,114 @@ func TestRouter_Call(t *testing.T) {
 	})
 }
 
+func TestRouter_DiscoveryModes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("off", func(t *testing.T) {
+		t.Parallel()
+
+		router, err := vshardrouter.NewRouter(ctx, vshardrouter.Config{
+			TopologyProvider: static.NewProvider(topology),
+			DiscoveryMode:    vshardrouter.DiscoveryModeOff,
+			TotalBucketCount: totalBucketCount,
+			User:             username,
+		})
+		require.NoError(t, err, "NewRouter started successfully")
+
+		// buckets are discovered on demand
+		_, err = router.Call(ctx, 1, vshardrouter.CallModeBRO, "echo", []interface{}{}, vshardrouter.CallOpts{})
+		require.NoError(t, err)
+
+		require.NoError(t, router.Close(ctx), "router.Close with no err")
+	})
+
+	t.Run("adaptive", func(t *testing.T) {
+		t.Parallel()
+
+		router, err := vshardrouter.NewRouter(ctx, vshardrouter.Config{
+			TopologyProvider: static.NewProvider(topology),
+			DiscoveryTimeout: 100 * time.Millisecond,
+			DiscoveryMode:    vshardrouter.DiscoveryModeAdaptive,
+			TotalBucketCount: totalBucketCount,
+			User:             username,
+		})
+		require.NoError(t, err, "NewRouter started successfully")
+
+		// let cron discovery run a few fast iterations on the cleaned route map
+		router.RouteMapClean()
+		time.Sleep(300 * time.Millisecond)
+
+		_, err = router.Call(ctx, 1, vshardrouter.CallModeBRO, "echo", []interface{}{}, vshardrouter.CallOpts{})
+		require.NoError(t, err)
+
+		require.NoError(t, router.Close(ctx), "router.Close with no err")
+	})
+}
+
+func TestRouter_CallBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	router, err := vshardrouter.NewRouter(ctx, vshardrouter.Config{
+		TopologyProvider: static.NewProvider(topology),
+		DiscoveryTimeout: 5 * time.Second,
+		DiscoveryMode:    vshardrouter.DiscoveryModeOn,
+		TotalBucketCount: totalBucketCount,
+		User:             username,
+	})
+	require.NoError(t, err, "NewRouter started successfully")
+
+	items := make([]vshardrouter.CallBatchItem, 0, totalBucketCount)
+	for bucketID := uint64(1); bucketID <= totalBucketCount; bucketID++ {
+		items = append(items, vshardrouter.CallBatchItem{
+			BucketID: bucketID,
+			Args:     []interface{}{bucketID},
+		})
+	}
+
+	results, err := router.CallBatch(ctx, vshardrouter.CallModeBRO, "echo", items, vshardrouter.CallOpts{})
+	require.NoError(t, err, "router.CallBatch with no err")
+	require.Len(t, results, len(items))
+
+	for i, result := range results {
+		require.NoErrorf(t, result.Err, "item %d with no err", i)
+
+		var bucketID uint64
+		err = result.Resp.GetTyped(&[]interface{}{&bucketID})
+		require.NoError(t, err, "GetTyped with no err")
+		require.Equal(t, items[i].BucketID, bucketID)
+	}
+}
+
+func TestRouter_Close(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	router, err := vshardrouter.NewRouter(ctx, vshardrouter.Config{
+		TopologyProvider: static.NewProvider(topology),
+		DiscoveryTimeout: 5 * time.Second,
+		DiscoveryMode:    vshardrouter.DiscoveryModeOn,
+		TotalBucketCount: totalBucketCount,
+		User:             username,
+	})
+	require.NoError(t, err, "NewRouter started successfully")
+
+	bucketID := randBucketID(totalBucketCount)
+
+	_, err = router.CallRW(ctx, bucketID, "echo", []interface{}{"arg"}, vshardrouter.CallOpts{})
+	require.NoError(t, err, "router.CallRW with no err")
+
+	err = router.Close(ctx)
+	require.NoError(t, err, "router.Close with no err")
+
+	_, err = router.CallRW(ctx, bucketID, "echo", []interface{}{"arg"}, vshardrouter.CallOpts{})
+	require.ErrorIs(t, err, vshardrouter.ErrRouterClosed)
+}
+
 func randBucketID(totalBucketCount uint64) uint64 {
 	//nolint:gosec
 	return (rand.Uint64() % totalBucketCount) + 1
@@ -582,6 +768,53 @@ func TestRouter_RouterMapCallRW(t *testing.T) {
 	_, err = vshardrouter.RouterMapCallRW[interface{}](router, ctx, "echo", nil, callOpts)
 	require.NotNil(t, err, "RouterMapCallRWImpl with nil args finished with error")
 
+	// RouterMapCallRWPartial returns a result for each replicaset
+	partial, err := vshardrouter.RouterMapCallRWPartial[string](router, ctx, "echo", []interface{}{arg},
+		vshardrouter.RouterMapCallRWPartialOptions{MinSuccess: len(topology)})
+	require.NoError(t, err, "RouterMapCallRWPartial echo finished with no err")
+	require.Len(t, partial, len(topology))
+
+	var partialBucketCount uint64
+	for k, v := range partial {
+		require.NoErrorf(t, v.Err, "RouterMapCallRWPartial no err for %v", k)
+		require.Equalf(t, arg, v.Value, "RouterMapCallRWPartial value ok for %v", k)
+		partialBucketCount += v.BucketCount
+	}
+
+	require.Equal(t, uint64(totalBucketCount), partialBucketCount)
+
+	partial, err = vshardrouter.RouterMapCallRWPartial[string](router, ctx, "raise_luajit_error", noArgs,
+		vshardrouter.RouterMapCallRWPartialOptions{MinSuccess: 1})
+	require.ErrorIs(t, err, vshardrouter.ErrMapCallNotEnoughResults)
+
+	for k, v := range partial {
+		require.ErrorIsf(t, v.Err, vshardrouter.ErrMapCallMap, "RouterMapCallRWPartial map err for %v", k)
+	}
+
+	// RouterMapCallRWReduce streams the results
+	var reduced int
+	err = vshardrouter.RouterMapCallRWReduce[string](router, ctx, "echo", []interface{}{arg}, callOpts,
+		func(rsName string, value string) bool {
+			require.Equalf(t, arg, value, "RouterMapCallRWReduce value ok for %v", rsName)
+			reduced++
+
+			return true
+		})
+	require.NoError(t, err, "RouterMapCallRWReduce echo finished with no err")
+	require.Equal(t, len(topology), reduced)
+
+	// RouterMapCallRO calls replicas without refs
+	readOnly, err := vshardrouter.RouterMapCallRO[string](router, ctx, "echo", []interface{}{arg},
+		vshardrouter.RouterMapCallROOptions{Mode: vshardrouter.CallModeRE, CheckBucketCount: true})
+	require.NoError(t, err, "RouterMapCallRO echo finished with no err")
+	require.Len(t, readOnly, len(topology))
+
+	for k, v := range readOnly {
+		require.NoErrorf(t, v.Err, "RouterMapCallRO no err for %v", k)
+		require.Equalf(t, arg, v.Value, "RouterMapCallRO value ok for %v", k)
+		require.NotEmptyf(t, v.Instance, "RouterMapCallRO instance is known for %v", k)
+	}
+
 	// Ensure that RouterMapCallRWImpl doesn't work when it mean't to
 	for rsInfo := range topology {
 		errs := router.RemoveReplicaset(ctx, rsInfo.Name)
@@ -593,6 +826,115 @@ func TestRouter_RouterMapCallRW(t *testing.T) {
 	require.NotNilf(t, err, "RouterMapCallRWImpl failed on not full cluster")
 }
 
+func TestRouter_Interceptors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	var infos []*vshardrouter.CallInfo
+
+	router, err := vshardrouter.NewRouter(ctx, vshardrouter.Config{
+		TopologyProvider: static.NewProvider(topology),
+		DiscoveryTimeout: 5 * time.Second,
+		DiscoveryMode:    vshardrouter.DiscoveryModeOn,
+		TotalBucketCount: totalBucketCount,
+		User:             username,
+		Interceptors: []vshardrouter.CallInterceptor{
+			func(ctx context.Context, info *vshardrouter.CallInfo, invoker vshardrouter.CallInvoker) (interface{}, error) {
+				reply, err := invoker(ctx, info)
+				infos = append(infos, info)
+
+				return reply, err
+			},
+		},
+	})
+	require.Nil(t, err, "NewRouter created successfully")
+
+	_, err = vshardrouter.RouterMapCallRW[string](router, ctx, "echo", []interface{}{"arg"},
+		vshardrouter.RouterMapCallRWOptions{})
+	require.NoError(t, err, "RouterMapCallRW echo finished with no err")
+
+	resp := router.Do(vshardrouter.NewCallRequest("echo").BucketID(1).Args([]interface{}{"arg"}), pool.RW)
+	_, err = resp.Get()
+	require.NoError(t, err, "Do echo finished with no err")
+
+	require.Len(t, infos, 2)
+
+	require.True(t, infos[0].MapReduce)
+	require.Len(t, infos[0].Attempts, len(topology))
+
+	require.False(t, infos[1].MapReduce)
+	require.Equal(t, uint64(1), infos[1].BucketID)
+	require.Equal(t, vshardrouter.CallModeRW, infos[1].Mode)
+	require.Len(t, infos[1].Attempts, 1)
+	require.NoError(t, infos[1].Attempts[0].Err)
+}
+
+func TestRouter_ReplicationHealth(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	router, err := vshardrouter.NewRouter(ctx, vshardrouter.Config{
+		TopologyProvider: static.NewProvider(topology),
+		DiscoveryTimeout: 5 * time.Second,
+		DiscoveryMode:    vshardrouter.DiscoveryModeOn,
+		TotalBucketCount: totalBucketCount,
+		User:             username,
+		ReplicationHealth: &vshardrouter.ReplicationHealthOpts{
+			PollInterval: 50 * time.Millisecond,
+		},
+	})
+	require.Nil(t, err, "NewRouter created successfully")
+
+	defer func() {
+		require.NoError(t, router.Close(ctx), "router.Close with no err")
+	}()
+
+	// let the router poll replicas a few times
+	time.Sleep(200 * time.Millisecond)
+
+	bucketID := randBucketID(totalBucketCount)
+
+	resp, err := router.CallRO(ctx, bucketID, "echo", []interface{}{"arg"}, vshardrouter.CallOpts{})
+	require.NoError(t, err, "router.CallRO with no err")
+
+	var result string
+	err = resp.GetTyped(&[]interface{}{&result})
+	require.NoError(t, err, "GetTyped with no err")
+	require.Equal(t, "arg", result)
+}
+
+func TestRouter_Balancer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	router, err := vshardrouter.NewRouter(ctx, vshardrouter.Config{
+		TopologyProvider: static.NewProvider(topology),
+		DiscoveryTimeout: 5 * time.Second,
+		DiscoveryMode:    vshardrouter.DiscoveryModeOn,
+		TotalBucketCount: totalBucketCount,
+		User:             username,
+		Balancer:         &vshardrouter.BalancerOpts{},
+	})
+	require.Nil(t, err, "NewRouter created successfully")
+
+	defer func() {
+		require.NoError(t, router.Close(ctx), "router.Close with no err")
+	}()
+
+	for i := 0; i < 10; i++ {
+		bucketID := randBucketID(totalBucketCount)
+
+		_, err = router.CallBRO(ctx, bucketID, "echo", []interface{}{"arg"}, vshardrouter.CallOpts{})
+		require.NoError(t, err, "router.CallBRO with no err")
+
+		_, err = router.CallBRE(ctx, bucketID, "echo", []interface{}{"arg"}, vshardrouter.CallOpts{})
+		require.NoError(t, err, "router.CallBRE with no err")
+	}
+}
+
 func TestRouterRoute(t *testing.T) {
 	t.Parallel()
 
@@ -3,6 +3,7 @@ package vshard_router //nolint:revive
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/tarantool/go-tarantool/v2"
 	"github.com/tarantool/go-tarantool/v2/pool"
@@ -86,7 +87,14 @@ func (r *Router) AddInstance(ctx context.Context, rsName string, info InstanceIn
 		return ErrReplicasetNotExists
 	}
 
-	return rs.conn.Add(ctx, instance)
+	err = rs.conn.Add(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	rs.addInstanceInfo(info)
+
+	return nil
 }
 
 // RemoveInstance removes a specific instance from the router topology within a replicaset.
@@ -120,7 +128,14 @@ func (r *Router) RemoveInstance(ctx context.Context, rsName, instanceName string
 		return ErrReplicasetNotExists
 	}
 
-	return rs.conn.Remove(instanceName)
+	err := rs.conn.Remove(instanceName)
+	if err != nil {
+		return err
+	}
+
+	rs.removeInstanceInfo(instanceName)
+
+	return nil
 }
 
 func (r *Router) AddReplicaset(ctx context.Context, rsInfo ReplicasetInfo, instances []InstanceInfo) error {
@@ -176,9 +191,35 @@ func (r *Router) AddReplicaset(ctx context.Context, rsInfo ReplicasetInfo, insta
 		r.log().Errorf(ctx, "got connected now as false to pool.RW")
 	}
 
-	replicaset := &Replicaset{
-		info: rsInfo,
-		conn: conn,
+	replicaset := newReplicaset(rsInfo, conn, instances)
+
+	replicaset.limiters = r.newReplicasetLimiters(rsInfo.Name)
+	replicaset.zones = r.newZoneRouting(instances)
+
+	if r.cfg.ReplicationHealth != nil {
+		replicaset.health = &replicationHealth{}
+	}
+
+	if r.cfg.Balancer != nil {
+		var onScore func(instance string, latency time.Duration, errorRate, score float64)
+		if m, ok := r.metrics().(BalancerMetricsProvider); ok {
+			onScore = func(instance string, latency time.Duration, errorRate, score float64) {
+				m.InstanceScore(rsInfo.Name, instance, latency, errorRate, score)
+			}
+		}
+
+		replicaset.balancer = newBalancer(*r.cfg.Balancer, onScore)
+	}
+
+	if r.cfg.CircuitBreaker != nil {
+		replicaset.breaker = newCircuitBreaker(*r.cfg.CircuitBreaker, func(state CircuitBreakerState) {
+			// the breaker lives longer than AddReplicaset call, so its context is not used here
+			r.log().Warnf(context.Background(), "Circuit breaker of replicaset '%s' is %s now", rsInfo.Name, state)
+
+			if m, ok := r.metrics().(CircuitBreakerMetricsProvider); ok {
+				m.CircuitBreakerStateChange(rsInfo.Name, state)
+			}
+		})
 	}
 
 	// Create an entirely new map object
@@ -2,7 +2,9 @@ package vshard_router //nolint:revive
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -22,6 +24,8 @@ var (
 	ErrInvalidReplicasetInfo = fmt.Errorf("invalid replicaset info")
 	// ErrTopologyProvider is returned when there is an error from the topology provider.
 	ErrTopologyProvider = fmt.Errorf("got error from topology provider")
+	// ErrRouterClosed is returned when the router has been closed.
+	ErrRouterClosed = fmt.Errorf("router is closed")
 )
 
 type routeMap = []atomic.Pointer[Replicaset]
@@ -43,7 +47,19 @@ type Router struct {
 	// we made it global and monotonically growing for each Router instance.
 	refID atomic.Int64
 
+	// cancelDiscovery stops cron discovery and waits until it exits.
 	cancelDiscovery func()
+	// adaptiveDiscovery is not nil in DiscoveryModeAdaptive.
+	adaptiveDiscovery *adaptiveDiscovery
+	// cancelReplicationHealth stops replication health checks and waits until they exit.
+	cancelReplicationHealth func()
+
+	// closed is set by Router.Close. New requests are rejected after that.
+	closed atomic.Bool
+	// inflightMutex guards inflight against Add after Wait has been started by Router.Close.
+	inflightMutex sync.RWMutex
+	// inflight tracks requests in progress, so Router.Close can wait for them.
+	inflight sync.WaitGroup
 }
 
 func (r *Router) metrics() MetricsProvider {
@@ -54,6 +70,24 @@ func (r *Router) log() LogfProvider {
 	return r.cfg.Loggerf
 }
 
+// beginRequest registers an in-flight request. Every successful call must be paired with endRequest.
+func (r *Router) beginRequest() error {
+	r.inflightMutex.RLock()
+	defer r.inflightMutex.RUnlock()
+
+	if r.closed.Load() {
+		return ErrRouterClosed
+	}
+
+	r.inflight.Add(1)
+
+	return nil
+}
+
+func (r *Router) endRequest() {
+	r.inflight.Done()
+}
+
 func (r *Router) getRouteMap() routeMap {
 	ptr := r.routeMap.Load()
 	return *ptr
@@ -72,12 +106,44 @@ type Config struct {
 
 	// Discovery
 	// DiscoveryTimeout is timeout between cron discovery job; by default there is no timeout.
+	// DiscoveryModeAdaptive changes it depending on the state of the route map.
 	DiscoveryTimeout time.Duration
 	DiscoveryMode    DiscoveryMode
 	// DiscoveryWorkStep is a pause between calling buckets_discovery on storage
 	// in buckets discovering logic. Default is 10ms.
+	// DiscoveryModeAdaptive makes no pause while the route map has unknown buckets.
 	DiscoveryWorkStep time.Duration
 
+	// Interceptors are called around Router.Call (and all methods based on it) and map-reduce calls,
+	// the first interceptor is the outermost one. Router.CallBatch is not intercepted.
+	Interceptors []CallInterceptor
+
+	// CircuitBreaker enables a circuit breaker for each replicaset, it is disabled by default.
+	CircuitBreaker *CircuitBreakerOpts
+
+	// Limits limits requests to each replicaset, there are no limits by default.
+	Limits *LimitsOpts
+
+	// ReplicationHealth enables replication lag and status checks of replicas for read requests,
+	// it is disabled by default.
+	ReplicationHealth *ReplicationHealthOpts
+
+	// Zone is a zone of the router. If it is set, read requests are sent to the instances of the nearest zone
+	// according to ZoneWeights (and InstanceInfo.Zone), more distant zones are used only if there are no
+	// available instances in the nearer ones. CallModeRO and CallModeRE requests are sent to the master
+	// if there are no available replicas.
+	Zone string
+	// ZoneWeights is a distance table between zones, see ZoneWeights.
+	ZoneWeights ZoneWeights
+
+	// Balancer enables latency-aware balancing of CallModeBRO and CallModeBRE requests,
+	// by default the pool chooses instances in round-robin.
+	Balancer *BalancerOpts
+
+	// RetryPolicy decides whether Router.Call should retry a failed attempt.
+	// It can be overridden by CallOpts.RetryPolicy. By default, the lua router behavior is reproduced.
+	RetryPolicy RetryPolicy
+
 	// BucketsSearchMode defines policy for Router.Route method.
 	// Default value is BucketsSearchLegacy.
 	// See BucketsSearchMode constants for more detail.
@@ -164,6 +230,9 @@ type InstanceInfo struct {
 	// The UUID ensures that each instance can be identified uniquely, but it is not required for basic operations.
 	UUID uuid.UUID
 
+	// Zone is an optional zone (e.g. datacenter) of the instance, see Config.Zone.
+	Zone string
+
 	// Dialer allows to use a custom dialer instead of the default one (tarantool.NetDialer).
 	// This parameter is temporarily optional and will become mandatory in the future.
 	Dialer tarantool.Dialer
@@ -217,25 +286,116 @@ func NewRouter(ctx context.Context, cfg Config) (*Router, error) {
 		return nil, fmt.Errorf("%w; cant init topology with err: %w", ErrTopologyProvider, err)
 	}
 
-	err = router.DiscoveryAllBuckets(ctx)
-	if err != nil {
-		router.log().Errorf(ctx, "router.DiscoveryAllBuckets failed: %v", err)
+	if cfg.DiscoveryMode != DiscoveryModeOff {
+		err = router.DiscoveryAllBuckets(ctx)
+		if err != nil {
+			router.log().Errorf(ctx, "router.DiscoveryAllBuckets failed: %v", err)
+		}
+	}
+
+	if cfg.DiscoveryMode == DiscoveryModeAdaptive {
+		router.adaptiveDiscovery = newAdaptiveDiscovery(cfg.DiscoveryTimeout)
 	}
 
-	if cfg.DiscoveryMode == DiscoveryModeOn {
+	if cfg.DiscoveryMode == DiscoveryModeOn || cfg.DiscoveryMode == DiscoveryModeAdaptive {
 		discoveryCronCtx, cancelFunc := context.WithCancel(ctx)
+		discoveryDone := make(chan struct{})
 
 		// run background cron discovery loop
 		// suppress linter warning: Non-inherited new context, use function like `context.WithXXX` instead (contextcheck)
 		//nolint:contextcheck
-		go router.cronDiscovery(discoveryCronCtx)
+		go func() {
+			defer close(discoveryDone)
+			router.cronDiscovery(discoveryCronCtx)
+		}()
+
+		router.cancelDiscovery = func() {
+			cancelFunc()
+			<-discoveryDone
+		}
+	}
+
+	if cfg.ReplicationHealth != nil {
+		healthCtx, cancelFunc := context.WithCancel(ctx)
+		healthDone := make(chan struct{})
 
-		router.cancelDiscovery = cancelFunc
+		//nolint:contextcheck
+		go func() {
+			defer close(healthDone)
+			router.cronReplicationHealth(healthCtx, *cfg.ReplicationHealth)
+		}()
+
+		router.cancelReplicationHealth = func() {
+			cancelFunc()
+			<-healthDone
+		}
 	}
 
 	return router, nil
 }
 
+// Close gracefully shuts the router down.
+// It stops cron discovery and replication health checks, rejects new requests with ErrRouterClosed and waits for in-flight requests
+// (Router.Call, RouterMapCallRW, etc.) to complete or ctx to expire. Then it closes the topology provider
+// and connection pools of all replicasets. Pools are closed gracefully if all requests have completed,
+// otherwise they are closed forcibly and ctx error is returned among the others.
+func (r *Router) Close(ctx context.Context) error {
+	r.inflightMutex.Lock()
+	alreadyClosed := r.closed.Swap(true)
+	r.inflightMutex.Unlock()
+
+	if alreadyClosed {
+		return ErrRouterClosed
+	}
+
+	r.log().Infof(ctx, "Closing router")
+
+	if r.cancelDiscovery != nil {
+		r.cancelDiscovery()
+	}
+
+	if r.cancelReplicationHealth != nil {
+		r.cancelReplicationHealth()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(drained)
+	}()
+
+	var errs []error
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		r.log().Warnf(ctx, "Router closing: in-flight requests have not completed: %v", ctx.Err())
+		errs = append(errs, fmt.Errorf("wait for in-flight requests: %w", ctx.Err()))
+	}
+
+	if r.cfg.TopologyProvider != nil {
+		r.cfg.TopologyProvider.Close()
+	}
+
+	for rsName, rs := range r.getNameToReplicaset() {
+		var closeErrs []error
+
+		if len(errs) == 0 {
+			closeErrs = rs.conn.CloseGraceful()
+		} else {
+			closeErrs = rs.conn.Close()
+		}
+
+		for _, err := range closeErrs {
+			errs = append(errs, fmt.Errorf("close replicaset %s: %w", rsName, err))
+		}
+	}
+
+	r.log().Infof(ctx, "Router closed")
+
+	return errors.Join(errs...)
+}
+
 // BucketSet Set a bucket to a replicaset.
 func (r *Router) BucketSet(bucketID uint64, rsName string) (*Replicaset, error) {
 	nameToReplicasetRef := r.getNameToReplicaset()
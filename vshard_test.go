@@ -1,9 +1,13 @@
 package vshard_router //nolint:revive
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	mockpool "github.com/tarantool/go-vshard-router/v2/mocks/pool"
 )
 
 func TestRouter_RouterBucketIDStrCRC32(t *testing.T) {
@@ -35,3 +39,63 @@ func TestRouter_RouteMapClean(t *testing.T) {
 		r.RouteMapClean()
 	})
 }
+
+type closeCountingTopologyProvider struct {
+	closeCount int
+}
+
+func (p *closeCountingTopologyProvider) Init(_ TopologyController) error { return nil }
+func (p *closeCountingTopologyProvider) Close()                          { p.closeCount++ }
+
+func TestRouter_Close(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("graceful close", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("CloseGraceful").Return(nil).Once()
+
+		tp := &closeCountingTopologyProvider{}
+
+		router := newTestRouter(mPool)
+		router.cfg.TopologyProvider = tp
+
+		var discoveryStopped bool
+		router.cancelDiscovery = func() { discoveryStopped = true }
+
+		require.NoError(t, router.Close(ctx))
+		require.True(t, discoveryStopped)
+		require.Equal(t, 1, tp.closeCount)
+
+		_, err := router.CallRW(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.ErrorIs(t, err, ErrRouterClosed)
+
+		_, err = RouterMapCallRW[interface{}](router, ctx, "echo", []interface{}{}, RouterMapCallRWOptions{})
+		require.ErrorIs(t, err, ErrRouterClosed)
+
+		require.ErrorIs(t, router.Close(ctx), ErrRouterClosed)
+	})
+
+	t.Run("in-flight requests are not drained", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := mockpool.NewPooler(t)
+		mPool.On("Close").Return(nil).Once()
+
+		router := newTestRouter(mPool)
+
+		// simulate a hung request
+		require.NoError(t, router.beginRequest())
+
+		closeCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+
+		err := router.Close(closeCtx)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		router.endRequest()
+	})
+}
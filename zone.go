@@ -0,0 +1,111 @@
+package vshard_router //nolint:revive
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+)
+
+// ZoneWeights is a distance table between zones, as weights in lua vshard config:
+// ZoneWeights[routerZone][replicaZone] is a distance from the router to the replicas of the zone.
+// The replicas of the router zone have zero distance, the replicas of the zones missing in the table
+// are the most distant ones.
+type ZoneWeights map[string]map[string]float64
+
+// zoneRouting keeps the zones of replicaset instances to prefer the nearest ones for read requests.
+type zoneRouting struct {
+	zone    string
+	weights map[string]float64
+
+	mutex         sync.RWMutex
+	instanceZones map[string]string
+}
+
+func (r *Router) newZoneRouting(instances []InstanceInfo) *zoneRouting {
+	if r.cfg.Zone == "" {
+		return nil
+	}
+
+	zr := &zoneRouting{
+		zone:          r.cfg.Zone,
+		weights:       r.cfg.ZoneWeights[r.cfg.Zone],
+		instanceZones: make(map[string]string, len(instances)),
+	}
+
+	for _, instance := range instances {
+		zr.set(instance.Name, instance.Zone)
+	}
+
+	return zr
+}
+
+func (zr *zoneRouting) set(instance, zone string) {
+	if zr == nil {
+		return
+	}
+
+	zr.mutex.Lock()
+	defer zr.mutex.Unlock()
+
+	zr.instanceZones[instance] = zone
+}
+
+func (zr *zoneRouting) remove(instance string) {
+	if zr == nil {
+		return
+	}
+
+	zr.mutex.Lock()
+	defer zr.mutex.Unlock()
+
+	delete(zr.instanceZones, instance)
+}
+
+// distanceLocked must be called with mutex locked.
+func (zr *zoneRouting) distanceLocked(instance string) float64 {
+	zone := zr.instanceZones[instance]
+	if zone == zr.zone {
+		return 0
+	}
+
+	if weight, ok := zr.weights[zone]; ok {
+		return weight
+	}
+
+	return math.Inf(1)
+}
+
+// sort sorts instances from the nearest to the most distant ones keeping the order of equally distant instances.
+func (zr *zoneRouting) sort(instances []string) {
+	if zr == nil {
+		return
+	}
+
+	zr.mutex.RLock()
+	defer zr.mutex.RUnlock()
+
+	slices.SortStableFunc(instances, func(a, b string) int {
+		return cmp.Compare(zr.distanceLocked(a), zr.distanceLocked(b))
+	})
+}
+
+// nearest returns the prefix of sorted instances with the same distance as the first one.
+func (zr *zoneRouting) nearest(instances []string) []string {
+	if zr == nil || len(instances) == 0 {
+		return instances
+	}
+
+	zr.mutex.RLock()
+	defer zr.mutex.RUnlock()
+
+	distance := zr.distanceLocked(instances[0])
+
+	for i := 1; i < len(instances); i++ {
+		if zr.distanceLocked(instances[i]) != distance {
+			return instances[:i]
+		}
+	}
+
+	return instances
+}
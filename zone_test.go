@@ -0,0 +1,144 @@
+package vshard_router // nolint: revive
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tarantool/go-tarantool/v2/pool"
+)
+
+func newTestZoneRouting(instances ...InstanceInfo) *zoneRouting {
+	router := &Router{
+		cfg: Config{
+			Zone: "dc1",
+			ZoneWeights: ZoneWeights{
+				"dc1": {"dc2": 1, "dc3": 2},
+				"dc2": {"dc1": 1},
+			},
+		},
+	}
+
+	return router.newZoneRouting(instances)
+}
+
+func TestZoneRouting(t *testing.T) {
+	t.Parallel()
+
+	zr := newTestZoneRouting(
+		InstanceInfo{Name: "unknown"},
+		InstanceInfo{Name: "far", Zone: "dc3"},
+		InstanceInfo{Name: "near_1", Zone: "dc2"},
+		InstanceInfo{Name: "local", Zone: "dc1"},
+		InstanceInfo{Name: "near_2", Zone: "dc2"},
+	)
+
+	instances := []string{"unknown", "far", "near_1", "local", "near_2"}
+	zr.sort(instances)
+	require.Equal(t, []string{"local", "near_1", "near_2", "far", "unknown"}, instances)
+
+	require.Equal(t, []string{"local"}, zr.nearest(instances))
+	require.Equal(t, []string{"near_1", "near_2"}, zr.nearest(instances[1:]))
+	require.Empty(t, zr.nearest(nil))
+
+	zr.remove("local")
+	zr.set("far", "dc1")
+
+	instances = []string{"local", "near_1", "far"}
+	zr.sort(instances)
+	require.Equal(t, []string{"far", "near_1", "local"}, instances)
+
+	// nil zone routing keeps the order
+	var nilRouting *zoneRouting
+
+	instances = []string{"b", "a"}
+	nilRouting.sort(instances)
+	require.Equal(t, []string{"b", "a"}, instances)
+}
+
+func TestRouter_Call_Zones(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	instances := []InstanceInfo{
+		{Name: "master", Zone: "dc1"},
+		{Name: "replica_dc2", Zone: "dc2"},
+		{Name: "replica_dc3", Zone: "dc3"},
+	}
+
+	t.Run("nearest replica", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{
+			"master":      {ConnectedNow: true, ConnRole: pool.MasterRole},
+			"replica_dc2": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+			"replica_dc3": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		})
+		mPool.On("DoInstance", mock.Anything, "replica_dc2").
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Twice()
+		mPool.On("DoInstance", mock.Anything, "master").
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.zones = newTestZoneRouting(instances...)
+
+		_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+
+		_, err = router.CallRE(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+
+		// the master is in the router zone
+		_, err = router.CallBRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+
+	t.Run("distant replica", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{
+			"master":      {ConnectedNow: true, ConnRole: pool.MasterRole},
+			"replica_dc2": {ConnectedNow: false, ConnRole: pool.ReplicaRole},
+			"replica_dc3": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		})
+		mPool.On("DoInstance", mock.Anything, "replica_dc3").
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.zones = newTestZoneRouting(instances...)
+
+		_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+
+	t.Run("chosen replica disconnected", func(t *testing.T) {
+		t.Parallel()
+
+		mPool := newInstancePoolerMock(t)
+		mPool.On("GetInfo").Return(map[string]pool.ConnectionInfo{
+			"master":      {ConnectedNow: true, ConnRole: pool.MasterRole},
+			"replica_dc2": {ConnectedNow: true, ConnRole: pool.ReplicaRole},
+		})
+		mPool.On("DoInstance", mock.Anything, "replica_dc2").
+			Return(newErrorFuture(pool.ErrNoHealthyInstance)).Once()
+		// the pool chooses another instance itself
+		mPool.On("Do", mock.Anything, pool.RO).
+			Return(newCallResponseFuture(t, []interface{}{true, "ok"})).Once()
+
+		router := newTestRouter(mPool)
+
+		rs := router.getNameToReplicaset()["replicaset_1"]
+		rs.zones = newTestZoneRouting(instances...)
+
+		_, err := router.CallRO(ctx, 1, "echo", []interface{}{}, CallOpts{})
+		require.NoError(t, err)
+	})
+}